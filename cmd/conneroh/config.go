@@ -0,0 +1,417 @@
+package conneroh
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/conneroisu/conneroh.com/internal/assets"
+	"github.com/conneroisu/conneroh.com/internal/logger"
+	"github.com/conneroisu/conneroh.com/internal/metrics"
+	"github.com/rotisserie/eris"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigVar names the environment variable holding the path of the
+// configuration file, when the -config flag is not given.
+const ConfigVar = "CONNEROH_CONFIG"
+
+// Config is the configuration of the server.
+//
+// It is read from, in increasing order of precedence, an optional TOML or
+// YAML file, the environment and the command line flags.
+type Config struct {
+	// Host is the address the server listens on.
+	Host string `toml:"host" yaml:"host"`
+	// Port is the port the server listens on.
+	Port int `toml:"port" yaml:"port"`
+	// DBPath is the SQLite database holding the content.
+	DBPath string `toml:"db_path" yaml:"db_path"`
+	// AssetBaseURL is the bucket URL assets are linked under.
+	AssetBaseURL string `toml:"asset_base_url" yaml:"asset_base_url"`
+	// LocalAssets links assets under the site itself instead of the bucket.
+	LocalAssets bool `toml:"local_assets" yaml:"local_assets"`
+
+	// TLSCertFile and TLSKeyFile serve the site over HTTPS with the
+	// certificate in them.
+	TLSCertFile string `toml:"tls_cert_file" yaml:"tls_cert_file"`
+	TLSKeyFile  string `toml:"tls_key_file" yaml:"tls_key_file"`
+	// ACMEHosts is a comma-separated list of the hosts to obtain
+	// certificates for from Let's Encrypt, instead of reading them from
+	// files.
+	ACMEHosts string `toml:"acme_hosts" yaml:"acme_hosts"`
+	// ACMECacheDir is where obtained certificates are kept.
+	ACMECacheDir string `toml:"acme_cache_dir" yaml:"acme_cache_dir"`
+	// ACMEEmail is the contact address given to the certificate authority.
+	ACMEEmail string `toml:"acme_email" yaml:"acme_email"`
+	// RedirectPort is the port redirecting HTTP to HTTPS when TLS is
+	// enabled, 0 for none.
+	RedirectPort int `toml:"redirect_port" yaml:"redirect_port"`
+	// H2C accepts HTTP/2 without TLS, from a proxy terminating TLS.
+	H2C bool `toml:"h2c" yaml:"h2c"`
+
+	// LogLevel is the minimum level of logged records.
+	LogLevel string `toml:"log_level" yaml:"log_level"`
+	// LogFormat is logger.FormatText or logger.FormatJSON.
+	LogFormat string `toml:"log_format" yaml:"log_format"`
+	// LogTimestamps adds the time to every logged record.
+	LogTimestamps bool `toml:"log_timestamps" yaml:"log_timestamps"`
+	// AdminToken is the bearer token required to change the log level at
+	// /admin/log-level, which is not served if it is empty.
+	AdminToken string `toml:"admin_token" yaml:"admin_token"`
+
+	// Debug logs every database query.
+	Debug bool `toml:"debug" yaml:"debug"`
+	// Preview shows drafts, scheduled and unlisted documents.
+	Preview bool `toml:"preview" yaml:"preview"`
+	// Metrics serves the Prometheus metrics at /metrics. It is off by
+	// default, as the metrics are public unless MetricsToken is set.
+	Metrics bool `toml:"metrics" yaml:"metrics"`
+	// MetricsToken is the bearer token required to read the metrics, which
+	// are public if it is empty.
+	MetricsToken string `toml:"metrics_token" yaml:"metrics_token"`
+
+	ReadTimeout       time.Duration `toml:"read_timeout" yaml:"read_timeout"`
+	WriteTimeout      time.Duration `toml:"write_timeout" yaml:"write_timeout"`
+	IdleTimeout       time.Duration `toml:"idle_timeout" yaml:"idle_timeout"`
+	ReadHeaderTimeout time.Duration `toml:"read_header_timeout" yaml:"read_header_timeout"`
+	// ShutdownTimeout bounds how long in-flight requests are waited for on
+	// shutdown.
+	ShutdownTimeout time.Duration `toml:"shutdown_timeout" yaml:"shutdown_timeout"`
+	// DrainDelay is how long the server reports not-ready before it stops
+	// accepting connections, so that the proxy stops routing to it first.
+	DrainDelay time.Duration `toml:"drain_delay" yaml:"drain_delay"`
+	// ContentPollInterval is how often the database is checked for content
+	// written by cmd/update.
+	ContentPollInterval time.Duration `toml:"content_poll_interval" yaml:"content_poll_interval"`
+
+	// PrintConfig prints the effective configuration and exits.
+	PrintConfig bool `toml:"-" yaml:"-"`
+}
+
+// DefaultConfig returns the configuration used for settings given nowhere.
+func DefaultConfig() Config {
+	return Config{
+		Host:                "0.0.0.0",
+		Port:                8080,
+		DBPath:              assets.DefaultDBPath,
+		AssetBaseURL:        assets.DefaultAssetBaseURL,
+		ACMECacheDir:        "certs",
+		LogLevel:            "info",
+		LogFormat:           logger.FormatText,
+		ReadTimeout:         15 * time.Second,
+		WriteTimeout:        15 * time.Second,
+		IdleTimeout:         60 * time.Second,
+		ReadHeaderTimeout:   5 * time.Second,
+		ShutdownTimeout:     10 * time.Second,
+		DrainDelay:          2 * time.Second,
+		ContentPollInterval: 30 * time.Second,
+	}
+}
+
+// flags registers a flag for every setting of c on fs, returning the
+// environment variable of each flag that has one.
+func (c *Config) flags(fs *flag.FlagSet) map[string]string {
+	fs.StringVar(&c.Host, "host", c.Host, "address to listen on")
+	fs.IntVar(&c.Port, "port", c.Port, "port to listen on")
+	fs.StringVar(&c.DBPath, "db", c.DBPath, "SQLite database holding the content")
+	fs.StringVar(&c.AssetBaseURL, "asset-base-url", c.AssetBaseURL, "bucket URL assets are linked under")
+	fs.BoolVar(&c.LocalAssets, "local-assets", c.LocalAssets, "link assets under the site instead of the bucket")
+	fs.StringVar(&c.TLSCertFile, "tls-cert", c.TLSCertFile, "TLS certificate file")
+	fs.StringVar(&c.TLSKeyFile, "tls-key", c.TLSKeyFile, "TLS private key file")
+	fs.StringVar(&c.ACMEHosts, "acme-hosts", c.ACMEHosts, "comma-separated hosts to obtain Let's Encrypt certificates for")
+	fs.StringVar(&c.ACMECacheDir, "acme-cache-dir", c.ACMECacheDir, "directory obtained certificates are kept in")
+	fs.StringVar(&c.ACMEEmail, "acme-email", c.ACMEEmail, "contact address given to Let's Encrypt")
+	fs.IntVar(&c.RedirectPort, "redirect-port", c.RedirectPort, "port redirecting HTTP to HTTPS, 0 for none")
+	fs.BoolVar(&c.H2C, "h2c", c.H2C, "accept HTTP/2 without TLS from a proxy")
+	fs.StringVar(&c.LogLevel, "log-level", c.LogLevel, "minimum level logged: debug, info, warn or error")
+	fs.StringVar(&c.LogFormat, "log-format", c.LogFormat, "log format: text or json")
+	fs.BoolVar(&c.LogTimestamps, "log-timestamps", c.LogTimestamps, "add the time to every logged record")
+	fs.StringVar(&c.AdminToken, "admin-token", c.AdminToken, "bearer token required to change the log level")
+	fs.BoolVar(&c.Debug, "debug", c.Debug, "log every database query")
+	fs.BoolVar(&c.Preview, "preview", c.Preview, "show drafts, scheduled and unlisted documents")
+	fs.BoolVar(&c.Metrics, "metrics", c.Metrics, "serve Prometheus metrics at /metrics")
+	fs.StringVar(&c.MetricsToken, "metrics-token", c.MetricsToken, "bearer token required to read the metrics")
+	fs.DurationVar(&c.ReadTimeout, "read-timeout", c.ReadTimeout, "maximum duration of reading a request")
+	fs.DurationVar(&c.WriteTimeout, "write-timeout", c.WriteTimeout, "maximum duration of writing a response")
+	fs.DurationVar(&c.IdleTimeout, "idle-timeout", c.IdleTimeout, "how long idle keep-alive connections are kept")
+	fs.DurationVar(&c.ReadHeaderTimeout, "read-header-timeout", c.ReadHeaderTimeout, "maximum duration of reading request headers")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", c.ShutdownTimeout, "how long in-flight requests are waited for on shutdown")
+	fs.DurationVar(&c.DrainDelay, "drain-delay", c.DrainDelay, "how long readiness fails before shutting down")
+	fs.DurationVar(&c.ContentPollInterval, "content-poll-interval", c.ContentPollInterval, "how often the database is checked for new content")
+	fs.BoolVar(&c.PrintConfig, "print-config", c.PrintConfig, "print the effective configuration and exit")
+
+	return map[string]string{
+		"host":                  "HOST",
+		"port":                  "PORT",
+		"db":                    assets.DBPathVar,
+		"asset-base-url":        assets.AssetBaseURLVar,
+		"local-assets":          assets.LocalAssetsVar,
+		"tls-cert":              "TLS_CERT_FILE",
+		"tls-key":               "TLS_KEY_FILE",
+		"acme-hosts":            "ACME_HOSTS",
+		"acme-cache-dir":        "ACME_CACHE_DIR",
+		"acme-email":            "ACME_EMAIL",
+		"redirect-port":         "REDIRECT_PORT",
+		"h2c":                   "H2C",
+		"log-level":             "LOG_LEVEL",
+		"log-format":            "LOG_FORMAT",
+		"log-timestamps":        "LOG_TIMESTAMPS",
+		"admin-token":           "ADMIN_TOKEN",
+		"debug":                 "DEBUG",
+		"preview":               "PREVIEW",
+		"metrics":               "METRICS",
+		"metrics-token":         metrics.TokenVar,
+		"read-timeout":          "READ_TIMEOUT",
+		"write-timeout":         "WRITE_TIMEOUT",
+		"idle-timeout":          "IDLE_TIMEOUT",
+		"read-header-timeout":   "READ_HEADER_TIMEOUT",
+		"shutdown-timeout":      "SHUTDOWN_TIMEOUT",
+		"drain-delay":           "DRAIN_DELAY",
+		"content-poll-interval": "CONTENT_POLL_INTERVAL",
+	}
+}
+
+// LoadConfig reads the configuration from the file named by the -config
+// flag or ConfigVar, the environment and the flags in args, then validates
+// it.
+func LoadConfig(args []string, getenv func(string) string) (Config, error) {
+	cfg := DefaultConfig()
+	fs := flag.NewFlagSet("conneroh", flag.ContinueOnError)
+	path := fs.String("config", "", "TOML or YAML configuration file, also read from "+ConfigVar)
+	env := cfg.flags(fs)
+	err := fs.Parse(args)
+	if err != nil {
+		return Config{}, err
+	}
+	// The flags were parsed into cfg, so remember them before the file and
+	// the environment are applied below them.
+	given := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = f.Value.String()
+	})
+	cfg = DefaultConfig()
+
+	if *path == "" {
+		*path = getenv(ConfigVar)
+	}
+	if *path != "" {
+		err = cfg.readFile(*path)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+	for name, key := range env {
+		value := getenv(key)
+		if value == "" {
+			continue
+		}
+		err = fs.Set(name, value)
+		if err != nil {
+			return Config{}, eris.Wrapf(err, "invalid %s", key)
+		}
+	}
+	for name, value := range given {
+		err = fs.Set(name, value)
+		if err != nil {
+			return Config{}, eris.Wrapf(err, "invalid -%s", name)
+		}
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// readFile reads the settings in the TOML or YAML file at path into c,
+// rejecting unknown keys.
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return eris.Wrap(err, "failed to read config file")
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		meta, err := toml.Decode(string(data), c)
+		if err != nil {
+			return eris.Wrapf(err, "failed to parse %s", path)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return eris.Errorf("unknown setting %q in %s", undecoded[0].String(), path)
+		}
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(c)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return eris.Wrapf(err, "failed to parse %s", path)
+		}
+	default:
+		return eris.Errorf("config file must be .toml, .yaml or .yml: %s", path)
+	}
+
+	return nil
+}
+
+// Validate returns every problem with c joined into one error, or nil.
+func (c Config) Validate() error {
+	var errs []error
+	if c.Host == "" {
+		errs = append(errs, eris.New("host must not be empty"))
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, eris.Errorf("port must be between 1 and 65535: %d", c.Port))
+	}
+	if c.DBPath == "" {
+		errs = append(errs, eris.New("database path must not be empty"))
+	}
+	if !c.LocalAssets {
+		err := assets.ValidateAssetBaseURL(c.AssetBaseURL)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	errs = append(errs, c.validateTLS()...)
+	_, err := c.Level()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if c.LogFormat != logger.FormatText && c.LogFormat != logger.FormatJSON {
+		errs = append(errs, eris.Errorf("log format must be text or json: %q", c.LogFormat))
+	}
+	for name, d := range map[string]time.Duration{
+		"read timeout":          c.ReadTimeout,
+		"write timeout":         c.WriteTimeout,
+		"idle timeout":          c.IdleTimeout,
+		"read header timeout":   c.ReadHeaderTimeout,
+		"shutdown timeout":      c.ShutdownTimeout,
+		"content poll interval": c.ContentPollInterval,
+	} {
+		if d <= 0 {
+			errs = append(errs, eris.Errorf("%s must be positive: %s", name, d))
+		}
+	}
+	if c.DrainDelay < 0 {
+		errs = append(errs, eris.Errorf("drain delay must not be negative: %s", c.DrainDelay))
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateTLS returns the problems with the TLS settings of c.
+func (c Config) validateTLS() []error {
+	var errs []error
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		errs = append(errs, eris.New("TLS needs both a certificate and a key file"))
+	}
+	acme := len(c.ACMEHostList()) > 0
+	if acme && c.TLSCertFile != "" {
+		errs = append(errs, eris.New("TLS certificates come from either files or ACME, not both"))
+	}
+	if acme && c.ACMECacheDir == "" {
+		errs = append(errs, eris.New("ACME needs a cache directory"))
+	}
+	// The certificate authority connects to port 443 for TLS-ALPN-01
+	// challenges and to the redirect listener for HTTP-01 ones.
+	if acme && c.Port != 443 && c.RedirectPort == 0 {
+		errs = append(errs, eris.Errorf("ACME needs port 443 or a redirect port to answer challenges on, not port %d", c.Port))
+	}
+	if c.RedirectPort != 0 {
+		if !c.TLSEnabled() {
+			errs = append(errs, eris.New("the HTTPS redirect needs TLS"))
+		}
+		if c.RedirectPort < 0 || c.RedirectPort > 65535 || c.RedirectPort == c.Port {
+			errs = append(errs, eris.Errorf("invalid redirect port: %d", c.RedirectPort))
+		}
+	}
+	if c.H2C && c.TLSEnabled() {
+		errs = append(errs, eris.New("h2c is only for serving without TLS"))
+	}
+
+	return errs
+}
+
+// TLSEnabled returns true if the site is served over HTTPS.
+func (c Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" || len(c.ACMEHostList()) > 0
+}
+
+// ACMEHostList returns the hosts listed in ACMEHosts.
+func (c Config) ACMEHostList() []string {
+	var hosts []string
+	for host := range strings.SplitSeq(c.ACMEHosts, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+
+	return hosts
+}
+
+// Level returns the parsed LogLevel.
+func (c Config) Level() (slog.Level, error) {
+	return logger.ParseLevel(c.LogLevel)
+}
+
+// Addr returns the address the server listens on.
+func (c Config) Addr() string {
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+}
+
+// Redacted returns c without its secrets, for printing.
+func (c Config) Redacted() Config {
+	if c.MetricsToken != "" {
+		c.MetricsToken = "REDACTED"
+	}
+	if c.AdminToken != "" {
+		c.AdminToken = "REDACTED"
+	}
+
+	return c
+}
+
+// Print writes the effective configuration to w in TOML, without secrets.
+func (c Config) Print(w io.Writer) error {
+	return toml.NewEncoder(w).Encode(c.Redacted())
+}
+
+// LogValue implements slog.LogValuer, logging c without its secrets.
+func (c Config) LogValue() slog.Value {
+	c = c.Redacted()
+
+	return slog.GroupValue(
+		slog.String("addr", c.Addr()),
+		slog.String("db_path", c.DBPath),
+		slog.String("asset_base_url", c.AssetBaseURL),
+		slog.Bool("local_assets", c.LocalAssets),
+		slog.String("tls_cert_file", c.TLSCertFile),
+		slog.String("tls_key_file", c.TLSKeyFile),
+		slog.String("acme_hosts", c.ACMEHosts),
+		slog.String("acme_cache_dir", c.ACMECacheDir),
+		slog.String("acme_email", c.ACMEEmail),
+		slog.Int("redirect_port", c.RedirectPort),
+		slog.Bool("h2c", c.H2C),
+		slog.String("log_level", c.LogLevel),
+		slog.String("log_format", c.LogFormat),
+		slog.Bool("log_timestamps", c.LogTimestamps),
+		slog.String("admin_token", c.AdminToken),
+		slog.Bool("debug", c.Debug),
+		slog.Bool("preview", c.Preview),
+		slog.Bool("metrics", c.Metrics),
+		slog.String("metrics_token", c.MetricsToken),
+		slog.Duration("read_timeout", c.ReadTimeout),
+		slog.Duration("write_timeout", c.WriteTimeout),
+		slog.Duration("idle_timeout", c.IdleTimeout),
+		slog.Duration("read_header_timeout", c.ReadHeaderTimeout),
+		slog.Duration("shutdown_timeout", c.ShutdownTimeout),
+		slog.Duration("drain_delay", c.DrainDelay),
+		slog.Duration("content_poll_interval", c.ContentPollInterval),
+	)
+}
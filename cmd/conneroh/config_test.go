@@ -0,0 +1,289 @@
+package conneroh_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/conneroisu/conneroh.com/cmd/conneroh"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		env   map[string]string
+		args  []string
+		// want changes the default configuration into the expected one.
+		want func(*conneroh.Config)
+		// wantErr is part of the expected error, "" for none.
+		wantErr string
+	}{
+		{
+			name: "Defaults",
+			want: func(*conneroh.Config) {},
+		},
+		{
+			name:  "TOML file",
+			files: map[string]string{"c.toml": "port = 9000\nlog_level = \"debug\"\nread_timeout = \"20s\"\n"},
+			args:  []string{"-config", "c.toml"},
+			want: func(c *conneroh.Config) {
+				c.Port = 9000
+				c.LogLevel = "debug"
+				c.ReadTimeout = 20 * time.Second
+			},
+		},
+		{
+			name:  "YAML file",
+			files: map[string]string{"c.yaml": "port: 9000\nmetrics: true\nmetrics_token: secret\n"},
+			args:  []string{"-config", "c.yaml"},
+			want: func(c *conneroh.Config) {
+				c.Port = 9000
+				c.Metrics = true
+				c.MetricsToken = "secret"
+			},
+		},
+		{
+			name:  "Empty YAML file",
+			files: map[string]string{"c.yml": ""},
+			args:  []string{"-config", "c.yml"},
+			want:  func(*conneroh.Config) {},
+		},
+		{
+			name:  "File from the environment",
+			files: map[string]string{"c.toml": "port = 9000\n"},
+			env:   map[string]string{conneroh.ConfigVar: "c.toml"},
+			want:  func(c *conneroh.Config) { c.Port = 9000 },
+		},
+		{
+			name:  "Flag names the file over the environment",
+			files: map[string]string{"a.toml": "port = 9000\n", "b.toml": "port = 9001\n"},
+			env:   map[string]string{conneroh.ConfigVar: "a.toml"},
+			args:  []string{"-config", "b.toml"},
+			want:  func(c *conneroh.Config) { c.Port = 9001 },
+		},
+		{
+			name:  "Environment over file",
+			files: map[string]string{"c.toml": "port = 9000\nhost = \"127.0.0.1\"\n"},
+			env:   map[string]string{"PORT": "9100"},
+			args:  []string{"-config", "c.toml"},
+			want: func(c *conneroh.Config) {
+				c.Port = 9100
+				c.Host = "127.0.0.1"
+			},
+		},
+		{
+			name:  "Flags over environment and file",
+			files: map[string]string{"c.toml": "port = 9000\nlog_format = \"json\"\n"},
+			env:   map[string]string{"PORT": "9100", "LOG_LEVEL": "warn"},
+			args:  []string{"-config", "c.toml", "-port", "9200"},
+			want: func(c *conneroh.Config) {
+				c.Port = 9200
+				c.LogLevel = "warn"
+				c.LogFormat = "json"
+			},
+		},
+		{
+			name: "Flag set to its default over environment",
+			env:  map[string]string{"PORT": "9100"},
+			args: []string{"-port", "8080"},
+			want: func(*conneroh.Config) {},
+		},
+		{
+			name:    "Unknown TOML setting",
+			files:   map[string]string{"c.toml": "port = 9000\nprot = 9001\n"},
+			args:    []string{"-config", "c.toml"},
+			wantErr: `unknown setting "prot"`,
+		},
+		{
+			name:    "Unknown YAML setting",
+			files:   map[string]string{"c.yaml": "port: 9000\nprot: 9001\n"},
+			args:    []string{"-config", "c.yaml"},
+			wantErr: "field prot not found",
+		},
+		{
+			name:    "Print config is not a setting",
+			files:   map[string]string{"c.toml": "PrintConfig = true\n"},
+			args:    []string{"-config", "c.toml"},
+			wantErr: "unknown setting",
+		},
+		{
+			name:    "Unknown file extension",
+			files:   map[string]string{"c.json": "{}"},
+			args:    []string{"-config", "c.json"},
+			wantErr: "must be .toml, .yaml or .yml",
+		},
+		{
+			name:    "Missing file",
+			args:    []string{"-config", "missing.toml"},
+			wantErr: "failed to read config file",
+		},
+		{
+			name:    "Invalid environment value",
+			env:     map[string]string{"READ_TIMEOUT": "soon"},
+			wantErr: "invalid READ_TIMEOUT",
+		},
+		{
+			name:    "Unknown flag",
+			args:    []string{"-prot", "9000"},
+			wantErr: "flag provided but not defined",
+		},
+		{
+			name:    "Invalid setting",
+			env:     map[string]string{"LOG_FORMAT": "xml"},
+			wantErr: "log format must be text or json",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Clean(name), []byte(content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := conneroh.LoadConfig(tt.args, func(key string) string {
+				return tt.env[key]
+			})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("LoadConfig() error = %v, want %q", err, tt.wantErr)
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			want := conneroh.DefaultConfig()
+			tt.want(&want)
+			if got != want {
+				t.Errorf("LoadConfig() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		// change makes the default configuration invalid, or not.
+		change func(*conneroh.Config)
+		// wantErrs are parts of the expected error, none for no error.
+		wantErrs []string
+	}{
+		{name: "Defaults", change: func(*conneroh.Config) {}},
+		{
+			name:     "Empty host",
+			change:   func(c *conneroh.Config) { c.Host = "" },
+			wantErrs: []string{"host must not be empty"},
+		},
+		{
+			name:     "Port out of range",
+			change:   func(c *conneroh.Config) { c.Port = 70000 },
+			wantErrs: []string{"port must be between 1 and 65535"},
+		},
+		{
+			name:     "Empty database path",
+			change:   func(c *conneroh.Config) { c.DBPath = "" },
+			wantErrs: []string{"database path must not be empty"},
+		},
+		{
+			name:     "Unknown log level",
+			change:   func(c *conneroh.Config) { c.LogLevel = "loud" },
+			wantErrs: []string{"loud"},
+		},
+		{
+			name: "Non-positive durations",
+			change: func(c *conneroh.Config) {
+				c.ReadTimeout = 0
+				c.ContentPollInterval = -time.Second
+			},
+			wantErrs: []string{"read timeout must be positive", "content poll interval must be positive"},
+		},
+		{
+			name:     "Negative drain delay",
+			change:   func(c *conneroh.Config) { c.DrainDelay = -time.Second },
+			wantErrs: []string{"drain delay must not be negative"},
+		},
+		{
+			name:     "Certificate without key",
+			change:   func(c *conneroh.Config) { c.TLSCertFile = "cert.pem" },
+			wantErrs: []string{"TLS needs both a certificate and a key file"},
+		},
+		{
+			name: "Certificate files and ACME",
+			change: func(c *conneroh.Config) {
+				c.TLSCertFile, c.TLSKeyFile = "cert.pem", "key.pem"
+				c.ACMEHosts = "conneroh.com"
+			},
+			wantErrs: []string{"either files or ACME"},
+		},
+		{
+			name: "ACME on port 443",
+			change: func(c *conneroh.Config) {
+				c.ACMEHosts = "conneroh.com"
+				c.Port = 443
+			},
+		},
+		{
+			name: "ACME with a redirect port",
+			change: func(c *conneroh.Config) {
+				c.ACMEHosts = "conneroh.com"
+				c.Port = 8443
+				c.RedirectPort = 80
+			},
+		},
+		{
+			name:     "ACME without a port for challenges",
+			change:   func(c *conneroh.Config) { c.ACMEHosts = "conneroh.com" },
+			wantErrs: []string{"ACME needs port 443 or a redirect port"},
+		},
+		{
+			name:     "Redirect without TLS",
+			change:   func(c *conneroh.Config) { c.RedirectPort = 80 },
+			wantErrs: []string{"the HTTPS redirect needs TLS"},
+		},
+		{
+			name: "h2c with TLS",
+			change: func(c *conneroh.Config) {
+				c.TLSCertFile, c.TLSKeyFile = "cert.pem", "key.pem"
+				c.H2C = true
+			},
+			wantErrs: []string{"h2c is only for serving without TLS"},
+		},
+		{
+			name: "Every problem is reported",
+			change: func(c *conneroh.Config) {
+				c.Host = ""
+				c.Port = 0
+				c.LogFormat = "xml"
+			},
+			wantErrs: []string{"host must not be empty", "port must be between", "log format must be text or json"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := conneroh.DefaultConfig()
+			tt.change(&cfg)
+			err := cfg.Validate()
+			if len(tt.wantErrs) == 0 {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() error = nil, want %q", tt.wantErrs)
+			}
+			for _, want := range tt.wantErrs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() error = %v, want %q", err, want)
+				}
+			}
+		})
+	}
+}
@@ -1,23 +1,32 @@
 package conneroh
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"log/slog"
 	"net/http"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/a-h/templ"
 	"github.com/conneroisu/conneroh.com/cmd/conneroh/components"
 	"github.com/conneroisu/conneroh.com/cmd/conneroh/layouts"
 	"github.com/conneroisu/conneroh.com/cmd/conneroh/views"
 	"github.com/conneroisu/conneroh.com/internal/assets"
+	"github.com/conneroisu/conneroh.com/internal/metrics"
 	"github.com/conneroisu/conneroh.com/internal/routing"
+	"github.com/conneroisu/conneroh.com/internal/tracing"
 	"github.com/gorilla/schema"
+	"github.com/pmezard/go-difflib/difflib"
 	"github.com/rotisserie/eris"
 	"github.com/sourcegraph/conc/pool"
 	"github.com/uptrace/bun"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // ContactForm is the struct schema for the contact form.
@@ -79,22 +88,23 @@ func listHandler(
 		}
 		switch target {
 		case routing.PostPluralPath:
-			if len(allPosts) == 0 {
-				err = db.NewSelect().Model(&allPosts).
-					Order("updated_at").
+			posts, err := loadList(&allPosts, listedPosts, func(list *[]*assets.Post) error {
+				return db.NewSelect().Model(list).
+					Order("updated_at DESC").
 					Relation("Tags").
 					Relation("Posts").
 					Relation("Projects").
 					Scan(r.Context())
-				if err != nil {
-					return eris.Wrap(
-						err,
-						"failed to scan posts for post list",
-					)
-				}
+			})
+			if err != nil {
+				return eris.Wrap(
+					err,
+					"failed to scan posts for post list",
+				)
 			}
 			filtered, totalPages := filter(
-				allPosts,
+				r.Context(),
+				posts,
 				query,
 				page,
 				routing.MaxListLargeItems,
@@ -125,22 +135,23 @@ func listHandler(
 				)).ServeHTTP(w, r)
 			}
 		case routing.ProjectPluralPath:
-			if len(allProjects) == 0 {
-				err = db.NewSelect().Model(&allProjects).
-					Order("updated_at").
+			projects, err := loadList(&allProjects, listedProjects, func(list *[]*assets.Project) error {
+				return db.NewSelect().Model(list).
+					Order("updated_at DESC").
 					Relation("Tags").
 					Relation("Posts").
 					Relation("Projects").
 					Scan(r.Context())
-				if err != nil {
-					return eris.Wrap(
-						err,
-						"failed to scan projects for project list",
-					)
-				}
+			})
+			if err != nil {
+				return eris.Wrap(
+					err,
+					"failed to scan projects for project list",
+				)
 			}
 			filtered, totalPages := filter(
-				allProjects,
+				r.Context(),
+				projects,
 				query,
 				page,
 				routing.MaxListLargeItems,
@@ -171,22 +182,23 @@ func listHandler(
 				)).ServeHTTP(w, r)
 			}
 		case routing.TagsPluralPath:
-			if len(allTags) == 0 {
-				err = db.NewSelect().Model(&allTags).
-					Order("updated_at").
+			tags, err := loadList(&allTags, listedTags, func(list *[]*assets.Tag) error {
+				return db.NewSelect().Model(list).
+					Order("updated_at DESC").
 					Relation("Tags").
 					Relation("Posts").
 					Relation("Projects").
 					Scan(r.Context())
-				if err != nil {
-					return eris.Wrap(
-						err,
-						"failed to scan tags for tag list",
-					)
-				}
+			})
+			if err != nil {
+				return eris.Wrap(
+					err,
+					"failed to scan tags for tag list",
+				)
 			}
 			filtered, totalPages := filter(
-				allTags,
+				r.Context(),
+				tags,
 				query,
 				page,
 				routing.MaxListSmallItems,
@@ -217,23 +229,24 @@ func listHandler(
 				)).ServeHTTP(w, r)
 			}
 		case routing.EmploymentPluralPath:
-			if len(allEmployments) == 0 {
-				err = db.NewSelect().Model(&allEmployments).
-					Order("updated_at").
+			employments, err := loadList(&allEmployments, listedEmployments, func(list *[]*assets.Employment) error {
+				return db.NewSelect().Model(list).
+					Order("updated_at DESC").
 					Relation("Tags").
 					Relation("Posts").
 					Relation("Projects").
 					Relation("Employments").
 					Scan(r.Context())
-				if err != nil {
-					return eris.Wrap(
-						err,
-						"failed to scan employments for employment list",
-					)
-				}
+			})
+			if err != nil {
+				return eris.Wrap(
+					err,
+					"failed to scan employments for employment list",
+				)
 			}
 			filtered, totalPages := filter(
-				allEmployments,
+				r.Context(),
+				employments,
 				query,
 				page,
 				routing.MaxListLargeItems,
@@ -271,82 +284,86 @@ func listHandler(
 
 // HandleHome handles the home page. aka /{$}.
 func HandleHome(db *bun.DB) func(w http.ResponseWriter, r *http.Request) error {
-	var homePage *templ.Component
+	// Handler Component Cache, swapped atomically as requests are served
+	// concurrently.
+	var homePage atomic.Pointer[templ.Component]
 
 	return func(w http.ResponseWriter, r *http.Request) error {
 		var err error
-		if homePage != nil {
+		cached := homePage.Load()
+		metrics.CacheLookup("home", cached != nil)
+		if cached != nil {
 			routing.MorphableHandler(
 				layouts.Page,
-				*homePage,
+				*cached,
 			).ServeHTTP(w, r)
 
 			return nil
 		}
-		if len(allPosts) == 0 {
-			err = db.NewSelect().Model(&allPosts).
-				Order("updated_at").
+		posts, err := loadList(&allPosts, listedPosts, func(list *[]*assets.Post) error {
+			return db.NewSelect().Model(list).
+				Order("updated_at DESC").
 				Relation("Tags").
 				Relation("Posts").
 				Relation("Projects").
 				Scan(r.Context())
-			if err != nil {
-				return eris.Wrap(
-					err,
-					"failed to scan posts for home page",
-				)
-			}
+		})
+		if err != nil {
+			return eris.Wrap(
+				err,
+				"failed to scan posts for home page",
+			)
 		}
-		if len(allProjects) == 0 {
-			err = db.NewSelect().Model(&allProjects).
-				Order("updated_at").
+		projects, err := loadList(&allProjects, listedProjects, func(list *[]*assets.Project) error {
+			return db.NewSelect().Model(list).
+				Order("updated_at DESC").
 				Relation("Tags").
 				Relation("Posts").
 				Relation("Projects").
 				Scan(r.Context())
-			if err != nil {
-				return eris.Wrap(
-					err,
-					"failed to scan projects for home page",
-				)
-			}
+		})
+		if err != nil {
+			return eris.Wrap(
+				err,
+				"failed to scan projects for home page",
+			)
 		}
-		if len(allTags) == 0 {
-			err = db.NewSelect().Model(&allTags).
-				Order("updated_at").
+		tags, err := loadList(&allTags, listedTags, func(list *[]*assets.Tag) error {
+			return db.NewSelect().Model(list).
+				Order("updated_at DESC").
 				Relation("Tags").
 				Relation("Posts").
 				Relation("Projects").
 				Scan(r.Context())
-			if err != nil {
-				return eris.Wrap(
-					err,
-					"failed to scan tags for home page",
-				)
-			}
+		})
+		if err != nil {
+			return eris.Wrap(
+				err,
+				"failed to scan tags for home page",
+			)
 		}
-		if len(allEmployments) == 0 {
-			err = db.NewSelect().Model(&allEmployments).
-				Order("updated_at").
+		employments, err := loadList(&allEmployments, listedEmployments, func(list *[]*assets.Employment) error {
+			return db.NewSelect().Model(list).
+				Order("updated_at DESC").
 				Relation("Tags").
 				Relation("Posts").
 				Relation("Projects").
 				Relation("Employments").
 				Scan(r.Context())
-			if err != nil {
-				return eris.Wrap(
-					err,
-					"failed to scan employments for home page",
-				)
-			}
+		})
+		if err != nil {
+			return eris.Wrap(
+				err,
+				"failed to scan employments for home page",
+			)
 		}
 		home := views.Home(
-			&allPosts,
-			&allProjects,
-			&allTags,
-			&allEmployments,
+			&posts,
+			&projects,
+			&tags,
+			&employments,
 		)
-		homePage = &home
+		homePage.Store(&home)
 		routing.MorphableHandler(
 			layouts.Page,
 			home,
@@ -358,46 +375,49 @@ func HandleHome(db *bun.DB) func(w http.ResponseWriter, r *http.Request) error {
 
 // HandleProjects handles the projects page. aka /projects.
 func HandleProjects(db *bun.DB) routing.APIFunc {
-	// Handler Component Cache
-	var projectList *templ.Component
+	// Handler Component Cache, swapped atomically as requests are served
+	// concurrently.
+	var projectList atomic.Pointer[templ.Component]
 
 	return func(w http.ResponseWriter, r *http.Request) error {
-		if projectList != nil {
+		cached := projectList.Load()
+		metrics.CacheLookup("projects", cached != nil)
+		if cached != nil {
 			routing.MorphableHandler(
 				layouts.Page,
-				*projectList,
+				*cached,
 			).ServeHTTP(w, r)
 
 			return nil
 		}
-		if len(allProjects) == 0 {
-			err := db.NewSelect().Model(&allProjects).
-				Order("created_at").
+		projects, err := loadList(&allProjects, listedProjects, func(list *[]*assets.Project) error {
+			return db.NewSelect().Model(list).
+				Order("updated_at DESC").
 				Relation("Tags").
 				Relation("Posts").
 				Relation("Projects").
 				Scan(r.Context())
-			if err != nil {
-				return eris.Wrap(
-					err,
-					"failed to scan projects for projects page",
-				)
-			}
+		})
+		if err != nil {
+			return eris.Wrap(
+				err,
+				"failed to scan projects for projects page",
+			)
 		}
-		projects := views.List(
+		page := views.List(
 			routing.ProjectPluralPath,
 			nil,
-			&allProjects,
+			&projects,
 			nil,
 			nil,
 			"",
 			1,
-			(len(allProjects)+routing.MaxListLargeItems-1)/routing.MaxListLargeItems,
+			(len(projects)+routing.MaxListLargeItems-1)/routing.MaxListLargeItems,
 		)
-		projectList = &projects
+		projectList.Store(&page)
 		routing.MorphableHandler(
 			layouts.Page,
-			projects,
+			page,
 		).ServeHTTP(w, r)
 
 		return nil
@@ -406,8 +426,12 @@ func HandleProjects(db *bun.DB) routing.APIFunc {
 
 // HandlePost handles the post page. aka /post/{slug...}.
 func HandlePost(db *bun.DB) routing.APIFunc {
-	// Handler Component Slug-Mapped Cache
-	postMap := map[string]templ.Component{}
+	// Handler Component Slug-Mapped Cache, guarded by postMu as requests
+	// are served concurrently.
+	var (
+		postMu  sync.RWMutex
+		postMap = map[string]templ.Component{}
+	)
 
 	return func(w http.ResponseWriter, r *http.Request) error {
 		var (
@@ -416,7 +440,11 @@ func HandlePost(db *bun.DB) routing.APIFunc {
 			ok   bool
 			slug = routing.Slug(r)
 		)
-		if comp, ok = postMap[slug]; ok {
+		postMu.RLock()
+		comp, ok = postMap[slug]
+		postMu.RUnlock()
+		metrics.CacheLookup("post", ok)
+		if ok {
 			routing.MorphableHandler(
 				layouts.Page,
 				comp,
@@ -425,10 +453,17 @@ func HandlePost(db *bun.DB) routing.APIFunc {
 			return nil
 		}
 		err := db.NewSelect().Model(&p).
-			Where("slug = ?", slug).
+			Where("post.slug = ?", slug).
 			Relation("Tags").
 			Relation("Posts").
 			Relation("Projects").
+			Relation("Revisions", func(q *bun.SelectQuery) *bun.SelectQuery {
+				return q.Order("date DESC")
+			}).
+			Relation("Series").
+			Relation("Series.Posts", func(q *bun.SelectQuery) *bun.SelectQuery {
+				return q.Order("series_order")
+			}).
 			Limit(1).Scan(r.Context())
 		if err != nil {
 			slog.Error("failed to scan post", "err", err)
@@ -438,8 +473,228 @@ func HandlePost(db *bun.DB) routing.APIFunc {
 				"failed to scan post",
 			)
 		}
+		if !previewMode && !p.IsPublished(time.Now()) {
+			handleNotFound(w, r)
+
+			return nil
+		}
+		prunePost(&p)
 		comp = views.Post(&p)
+		postMu.Lock()
 		postMap[slug] = comp
+		postMu.Unlock()
+		routing.MorphableHandler(
+			layouts.Page,
+			comp,
+		).ServeHTTP(w, r)
+
+		return nil
+	}
+}
+
+// HandlePostDiff handles the diff between two revisions of a post.
+// aka /diff/post/{slug...}?from={hash}&to={hash}.
+func HandlePostDiff(db *bun.DB) routing.APIFunc {
+	// Handler Component Slug-Mapped Cache, guarded by diffMu as requests
+	// are served concurrently.
+	var (
+		diffMu  sync.RWMutex
+		diffMap = map[string]templ.Component{}
+	)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		var (
+			p        assets.Post
+			from, to assets.Revision
+			comp     templ.Component
+			ok       bool
+			slug     = routing.Slug(r)
+			fromHash = r.URL.Query().Get("from")
+			toHash   = r.URL.Query().Get("to")
+			key      = slug + "@" + fromHash + ".." + toHash
+		)
+		diffMu.RLock()
+		comp, ok = diffMap[key]
+		diffMu.RUnlock()
+		metrics.CacheLookup("post_diff", ok)
+		if ok {
+			routing.MorphableHandler(
+				layouts.Page,
+				comp,
+			).ServeHTTP(w, r)
+
+			return nil
+		}
+		err := db.NewSelect().Model(&p).
+			Where("slug = ?", slug).
+			Limit(1).Scan(r.Context())
+		if err != nil {
+			return eris.Wrap(
+				err,
+				"failed to scan post for diff",
+			)
+		}
+		if !previewMode && !p.IsPublished(time.Now()) {
+			handleNotFound(w, r)
+
+			return nil
+		}
+		err = db.NewSelect().Model(&from).
+			Where("doc_path = ? AND hash = ?", p.Path, fromHash).
+			Limit(1).Scan(r.Context())
+		if errors.Is(err, sql.ErrNoRows) {
+			handleNotFound(w, r)
+
+			return nil
+		}
+		if err != nil {
+			return eris.Wrapf(err, "failed to scan revision %s", fromHash)
+		}
+		err = db.NewSelect().Model(&to).
+			Where("doc_path = ? AND hash = ?", p.Path, toHash).
+			Limit(1).Scan(r.Context())
+		if errors.Is(err, sql.ErrNoRows) {
+			handleNotFound(w, r)
+
+			return nil
+		}
+		if err != nil {
+			return eris.Wrapf(err, "failed to scan revision %s", toHash)
+		}
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(from.Source),
+			B:        difflib.SplitLines(to.Source),
+			FromFile: from.ShortHash(),
+			ToFile:   to.ShortHash(),
+			Context:  3,
+		})
+		if err != nil {
+			return eris.Wrap(err, "failed to diff revisions")
+		}
+		comp = views.PostDiff(&p, &from, &to, diff)
+		diffMu.Lock()
+		diffMap[key] = comp
+		diffMu.Unlock()
+		routing.MorphableHandler(
+			layouts.Page,
+			comp,
+		).ServeHTTP(w, r)
+
+		return nil
+	}
+}
+
+// HandleSeries handles the series page. aka /series/{slug...}.
+func HandleSeries(db *bun.DB) routing.APIFunc {
+	// Handler Component Slug-Mapped Cache, guarded by seriesMu as requests
+	// are served concurrently.
+	var (
+		seriesMu  sync.RWMutex
+		seriesMap = map[string]templ.Component{}
+	)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		var (
+			s    assets.Series
+			comp templ.Component
+			ok   bool
+			slug = routing.Slug(r)
+		)
+		seriesMu.RLock()
+		comp, ok = seriesMap[slug]
+		seriesMu.RUnlock()
+		metrics.CacheLookup("series", ok)
+		if ok {
+			routing.MorphableHandler(
+				layouts.Page,
+				comp,
+			).ServeHTTP(w, r)
+
+			return nil
+		}
+		err := db.NewSelect().Model(&s).
+			Where("slug = ?", slug).
+			Relation("Posts", func(q *bun.SelectQuery) *bun.SelectQuery {
+				return q.Order("series_order")
+			}).
+			Relation("Posts.Tags").
+			Relation("Posts.Projects").
+			Limit(1).Scan(r.Context())
+		if errors.Is(err, sql.ErrNoRows) {
+			handleNotFound(w, r)
+
+			return nil
+		}
+		if err != nil {
+			return eris.Wrap(
+				err,
+				"failed to scan series",
+			)
+		}
+		s.Posts = listed(s.Posts)
+		comp = views.Series(&s)
+		seriesMu.Lock()
+		seriesMap[slug] = comp
+		seriesMu.Unlock()
+		routing.MorphableHandler(
+			layouts.Page,
+			comp,
+		).ServeHTTP(w, r)
+
+		return nil
+	}
+}
+
+// HandleMedia handles the media page. aka /media/{slug...}.
+func HandleMedia(db *bun.DB) routing.APIFunc {
+	// Handler Component Path-Mapped Cache, guarded by mediaMu as requests
+	// are served concurrently.
+	var (
+		mediaMu  sync.RWMutex
+		mediaMap = map[string]templ.Component{}
+	)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		var (
+			m    assets.Media
+			comp templ.Component
+			ok   bool
+			path = routing.Slug(r)
+		)
+		mediaMu.RLock()
+		comp, ok = mediaMap[path]
+		mediaMu.RUnlock()
+		metrics.CacheLookup("media", ok)
+		if ok {
+			routing.MorphableHandler(
+				layouts.Page,
+				comp,
+			).ServeHTTP(w, r)
+
+			return nil
+		}
+		err := db.NewSelect().Model(&m).
+			Where("path = ?", path).
+			Relation("Posts").
+			Relation("Projects").
+			Limit(1).Scan(r.Context())
+		if errors.Is(err, sql.ErrNoRows) {
+			handleNotFound(w, r)
+
+			return nil
+		}
+		if err != nil {
+			return eris.Wrap(
+				err,
+				"failed to scan media",
+			)
+		}
+		m.Posts = listed(m.Posts)
+		m.Projects = listed(m.Projects)
+		comp = views.Media(&m)
+		mediaMu.Lock()
+		mediaMap[path] = comp
+		mediaMu.Unlock()
 		routing.MorphableHandler(
 			layouts.Page,
 			comp,
@@ -451,8 +706,12 @@ func HandlePost(db *bun.DB) routing.APIFunc {
 
 // HandleProject handles the project page. aka /project/{slug...}.
 func HandleProject(db *bun.DB) routing.APIFunc {
-	// Handler Component Slug-Mapped Cache
-	projectMap := map[string]templ.Component{}
+	// Handler Component Slug-Mapped Cache, guarded by projectMu as requests
+	// are served concurrently.
+	var (
+		projectMu  sync.RWMutex
+		projectMap = map[string]templ.Component{}
+	)
 
 	return func(w http.ResponseWriter, r *http.Request) error {
 		var (
@@ -461,7 +720,11 @@ func HandleProject(db *bun.DB) routing.APIFunc {
 			ok   bool
 			slug = routing.Slug(r)
 		)
-		if c, ok = projectMap[slug]; ok {
+		projectMu.RLock()
+		c, ok = projectMap[slug]
+		projectMu.RUnlock()
+		metrics.CacheLookup("project", ok)
+		if ok {
 			routing.MorphableHandler(
 				layouts.Page,
 				c,
@@ -481,8 +744,16 @@ func HandleProject(db *bun.DB) routing.APIFunc {
 				"failed to scan project",
 			)
 		}
+		if !previewMode && !p.IsPublished(time.Now()) {
+			handleNotFound(w, r)
+
+			return nil
+		}
+		pruneProject(&p)
 		c = views.Project(&p)
+		projectMu.Lock()
 		projectMap[slug] = c
+		projectMu.Unlock()
 		routing.MorphableHandler(
 			layouts.Page,
 			c,
@@ -494,44 +765,49 @@ func HandleProject(db *bun.DB) routing.APIFunc {
 
 // HandleTags handles the tags page. aka /tags.
 func HandleTags(db *bun.DB) routing.APIFunc {
-	// Handler Component Cache
-	var tagList *templ.Component
+	// Handler Component Cache, swapped atomically as requests are served
+	// concurrently.
+	var tagList atomic.Pointer[templ.Component]
 
 	return func(w http.ResponseWriter, r *http.Request) error {
-		if tagList != nil {
+		cached := tagList.Load()
+		metrics.CacheLookup("tags", cached != nil)
+		if cached != nil {
 			routing.MorphableHandler(
 				layouts.Page,
-				*tagList,
+				*cached,
 			).ServeHTTP(w, r)
 
 			return nil
 		}
-		err := db.NewSelect().Model(&allTags).
-			Order("created_at").
-			Relation("Tags").
-			Relation("Posts").
-			Relation("Projects").
-			Scan(r.Context())
+		tags, err := loadList(&allTags, listedTags, func(list *[]*assets.Tag) error {
+			return db.NewSelect().Model(list).
+				Order("updated_at DESC").
+				Relation("Tags").
+				Relation("Posts").
+				Relation("Projects").
+				Scan(r.Context())
+		})
 		if err != nil {
 			return eris.Wrap(
 				err,
 				"failed to scan tags for tags page",
 			)
 		}
-		tags := views.List(
+		page := views.List(
 			routing.TagsPluralPath,
 			nil,
 			nil,
-			&allTags,
+			&tags,
 			nil,
 			"",
 			1,
-			(len(allTags)+routing.MaxListSmallItems-1)/routing.MaxListSmallItems,
+			(len(tags)+routing.MaxListSmallItems-1)/routing.MaxListSmallItems,
 		)
-		tagList = &tags
+		tagList.Store(&page)
 		routing.MorphableHandler(
 			layouts.Page,
-			tags,
+			page,
 		).ServeHTTP(w, r)
 
 		return nil
@@ -540,8 +816,12 @@ func HandleTags(db *bun.DB) routing.APIFunc {
 
 // HandleTag handles the tag page. aka /tag/{slug...}.
 func HandleTag(db *bun.DB) routing.APIFunc {
-	// Handler Component Slug-Mapped Cache
-	tagMap := map[string]templ.Component{}
+	// Handler Component Slug-Mapped Cache, guarded by tagMu as requests
+	// are served concurrently.
+	var (
+		tagMu  sync.RWMutex
+		tagMap = map[string]templ.Component{}
+	)
 
 	return func(w http.ResponseWriter, r *http.Request) error {
 		var (
@@ -550,7 +830,11 @@ func HandleTag(db *bun.DB) routing.APIFunc {
 			ok   bool
 			slug = routing.Slug(r)
 		)
-		if comp, ok = tagMap[slug]; ok {
+		tagMu.RLock()
+		comp, ok = tagMap[slug]
+		tagMu.RUnlock()
+		metrics.CacheLookup("tag", ok)
+		if ok {
 			routing.MorphableHandler(
 				layouts.Page,
 				comp,
@@ -570,8 +854,11 @@ func HandleTag(db *bun.DB) routing.APIFunc {
 				"failed to scan tag",
 			)
 		}
+		pruneTag(&tag)
 		comp = views.Tag(&tag)
+		tagMu.Lock()
 		tagMap[slug] = comp
+		tagMu.Unlock()
 		routing.MorphableHandler(
 			layouts.Page,
 			comp,
@@ -583,46 +870,49 @@ func HandleTag(db *bun.DB) routing.APIFunc {
 
 // HandlePosts handles the posts page. aka /posts.
 func HandlePosts(db *bun.DB) routing.APIFunc {
-	// Handler Component Cache
-	var postList *templ.Component
+	// Handler Component Cache, swapped atomically as requests are served
+	// concurrently.
+	var postList atomic.Pointer[templ.Component]
 
 	return func(w http.ResponseWriter, r *http.Request) error {
-		if postList != nil {
+		cached := postList.Load()
+		metrics.CacheLookup("posts", cached != nil)
+		if cached != nil {
 			routing.MorphableHandler(
 				layouts.Page,
-				*postList,
+				*cached,
 			).ServeHTTP(w, r)
 
 			return nil
 		}
-		if len(allPosts) == 0 {
-			err := db.NewSelect().Model(&allPosts).
-				Order("created_at").
+		posts, err := loadList(&allPosts, listedPosts, func(list *[]*assets.Post) error {
+			return db.NewSelect().Model(list).
+				Order("updated_at DESC").
 				Relation("Tags").
 				Relation("Posts").
 				Relation("Projects").
 				Scan(r.Context())
-			if err != nil {
-				return eris.Wrap(
-					err,
-					"failed to scan posts for posts page",
-				)
-			}
+		})
+		if err != nil {
+			return eris.Wrap(
+				err,
+				"failed to scan posts for posts page",
+			)
 		}
-		posts := views.List(
+		page := views.List(
 			routing.PostPluralPath,
-			&allPosts,
+			&posts,
 			nil,
 			nil,
 			nil,
 			"",
 			1,
-			(len(allPosts)+routing.MaxListLargeItems-1)/routing.MaxListLargeItems,
+			(len(posts)+routing.MaxListLargeItems-1)/routing.MaxListLargeItems,
 		)
-		postList = &posts
+		postList.Store(&page)
 		routing.MorphableHandler(
 			layouts.Page,
-			posts,
+			page,
 		).ServeHTTP(w, r)
 
 		return nil
@@ -631,47 +921,50 @@ func HandlePosts(db *bun.DB) routing.APIFunc {
 
 // HandleEmployments handles the employments page. aka /employments.
 func HandleEmployments(db *bun.DB) routing.APIFunc {
-	// Handler Component Cache
-	var employmentList *templ.Component
+	// Handler Component Cache, swapped atomically as requests are served
+	// concurrently.
+	var employmentList atomic.Pointer[templ.Component]
 
 	return func(w http.ResponseWriter, r *http.Request) error {
-		if employmentList != nil {
+		cached := employmentList.Load()
+		metrics.CacheLookup("employments", cached != nil)
+		if cached != nil {
 			routing.MorphableHandler(
 				layouts.Page,
-				*employmentList,
+				*cached,
 			).ServeHTTP(w, r)
 
 			return nil
 		}
-		if len(allEmployments) == 0 {
-			err := db.NewSelect().Model(&allEmployments).
-				Order("created_at").
+		employments, err := loadList(&allEmployments, listedEmployments, func(list *[]*assets.Employment) error {
+			return db.NewSelect().Model(list).
+				Order("updated_at DESC").
 				Relation("Tags").
 				Relation("Posts").
 				Relation("Projects").
 				Relation("Employments").
 				Scan(r.Context())
-			if err != nil {
-				return eris.Wrap(
-					err,
-					"failed to scan employments for employments page",
-				)
-			}
+		})
+		if err != nil {
+			return eris.Wrap(
+				err,
+				"failed to scan employments for employments page",
+			)
 		}
-		employments := views.List(
+		page := views.List(
 			routing.EmploymentPluralPath,
 			nil,
 			nil,
 			nil,
-			&allEmployments,
+			&employments,
 			"",
 			1,
-			(len(allEmployments)+routing.MaxListLargeItems-1)/routing.MaxListLargeItems,
+			(len(employments)+routing.MaxListLargeItems-1)/routing.MaxListLargeItems,
 		)
-		employmentList = &employments
+		employmentList.Store(&page)
 		routing.MorphableHandler(
 			layouts.Page,
-			employments,
+			page,
 		).ServeHTTP(w, r)
 
 		return nil
@@ -680,8 +973,12 @@ func HandleEmployments(db *bun.DB) routing.APIFunc {
 
 // HandleEmployment handles the employment page. aka /employment/{slug...}.
 func HandleEmployment(db *bun.DB) routing.APIFunc {
-	// Handler Component Slug-Mapped Cache
-	employmentMap := map[string]templ.Component{}
+	// Handler Component Slug-Mapped Cache, guarded by employmentMu as requests
+	// are served concurrently.
+	var (
+		employmentMu  sync.RWMutex
+		employmentMap = map[string]templ.Component{}
+	)
 
 	return func(w http.ResponseWriter, r *http.Request) error {
 		var (
@@ -690,7 +987,11 @@ func HandleEmployment(db *bun.DB) routing.APIFunc {
 			ok   bool
 			slug = routing.Slug(r)
 		)
-		if comp, ok = employmentMap[slug]; ok {
+		employmentMu.RLock()
+		comp, ok = employmentMap[slug]
+		employmentMu.RUnlock()
+		metrics.CacheLookup("employment", ok)
+		if ok {
 			routing.MorphableHandler(
 				layouts.Page,
 				comp,
@@ -711,8 +1012,11 @@ func HandleEmployment(db *bun.DB) routing.APIFunc {
 				"failed to scan employment",
 			)
 		}
+		pruneEmployment(&emp)
 		comp = views.Employment(&emp)
+		employmentMu.Lock()
 		employmentMap[slug] = comp
+		employmentMu.Unlock()
 		routing.MorphableHandler(
 			layouts.Page,
 			comp,
@@ -722,25 +1026,110 @@ func HandleEmployment(db *bun.DB) routing.APIFunc {
 	}
 }
 
+// listed returns the documents that belong in listings, dropping drafts,
+// scheduled and unlisted documents unless the server runs in preview mode.
+func listed[T interface{ IsListed(time.Time) bool }](items []T) []T {
+	if previewMode {
+		return items
+	}
+	now := time.Now()
+	visible := make([]T, 0, len(items))
+	for _, item := range items {
+		if item.IsListed(now) {
+			visible = append(visible, item)
+		}
+	}
+
+	return visible
+}
+
+// listedPosts returns the listed posts with their hidden relations removed.
+func listedPosts(posts []*assets.Post) []*assets.Post {
+	posts = listed(posts)
+	for _, post := range posts {
+		prunePost(post)
+	}
+
+	return assets.GroupBySeries(posts)
+}
+
+// listedProjects returns the listed projects with their hidden relations removed.
+func listedProjects(projects []*assets.Project) []*assets.Project {
+	projects = listed(projects)
+	for _, project := range projects {
+		pruneProject(project)
+	}
+
+	return projects
+}
+
+// listedTags returns the tags with their hidden relations removed.
+func listedTags(tags []*assets.Tag) []*assets.Tag {
+	for _, tag := range tags {
+		pruneTag(tag)
+	}
+
+	return tags
+}
+
+// listedEmployments returns the employments with their hidden relations removed.
+func listedEmployments(employments []*assets.Employment) []*assets.Employment {
+	for _, employment := range employments {
+		pruneEmployment(employment)
+	}
+
+	return employments
+}
+
+// prunePost removes unlisted posts and projects from the relations of a post.
+func prunePost(post *assets.Post) {
+	post.Posts = listed(post.Posts)
+	post.Projects = listed(post.Projects)
+	if post.Series != nil {
+		post.Series.Posts = listed(post.Series.Posts)
+	}
+}
+
+// pruneProject removes unlisted posts and projects from the relations of a project.
+func pruneProject(project *assets.Project) {
+	project.Posts = listed(project.Posts)
+	project.Projects = listed(project.Projects)
+}
+
+// pruneTag removes unlisted posts and projects from the relations of a tag.
+func pruneTag(tag *assets.Tag) {
+	tag.Posts = listed(tag.Posts)
+	tag.Projects = listed(tag.Projects)
+}
+
+// pruneEmployment removes unlisted posts and projects from the relations of an employment.
+func pruneEmployment(employment *assets.Employment) {
+	employment.Posts = listed(employment.Posts)
+	employment.Projects = listed(employment.Projects)
+}
+
 // filter returns a paginated slice of items matching the search query, ranked by relevance across multiple fields.
 // The function scores and filters items concurrently, prioritizing matches in the title, description, content, tags, and icon fields depending on the item type.
 // Results are sorted by descending relevance before pagination. If the query is empty, all items are returned paginated.
 func filter[T any](
+	ctx context.Context,
 	items []T,
 	query string,
 	page int,
 	pageSize int,
 	titleGetter func(T) string,
 ) ([]T, int) {
-	// If query is empty, return all items
+	// If query is empty, return all items in their stored order
 	if query == "" {
-		sort.Slice(items, func(i, j int) bool {
-			return strings.ToLower(titleGetter(items[i])) <
-				strings.ToLower(titleGetter(items[j]))
-		})
-
 		return routing.Paginate(items, page, pageSize)
 	}
+	start := time.Now()
+	defer func() { metrics.ObserveSearch(time.Since(start)) }()
+	_, span := tracing.Start(ctx, "search",
+		attribute.String("query", query),
+		attribute.Int("items", len(items)),
+	)
+	defer span.End()
 
 	p := pool.New().WithMaxGoroutines(maxSearchRoutines)
 	query = strings.ToLower(query)
@@ -873,3 +1262,23 @@ func filter[T any](
 	// Paginate the filtered results
 	return routing.Paginate(filtered, page, pageSize)
 }
+
+// handlePanic renders the 500 page for a request whose handler panicked, as
+// a fragment for htmx requests.
+func handlePanic(w http.ResponseWriter, r *http.Request) {
+	comp := views.Code500()
+	if r.Header.Get(routing.HdrRequest) == "" {
+		comp = layouts.Page(comp)
+	}
+	templ.Handler(comp, templ.WithStatus(http.StatusInternalServerError)).ServeHTTP(w, r)
+}
+
+// handleNotFound renders the not found page, such as for drafts and scheduled
+// documents requested outside of preview mode.
+func handleNotFound(w http.ResponseWriter, r *http.Request) {
+	comp := views.Code404()
+	if r.Header.Get(routing.HdrRequest) == "" {
+		comp = layouts.Page(comp)
+	}
+	templ.Handler(comp, templ.WithStatus(http.StatusNotFound)).ServeHTTP(w, r)
+}
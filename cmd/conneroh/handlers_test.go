@@ -0,0 +1,128 @@
+package conneroh
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/conneroisu/conneroh.com/internal/assets"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+)
+
+// newTestServer returns a server configured by cfg over a new database
+// seeded by seed, if not nil.
+func newTestServer(t *testing.T, cfg Config, seed func(context.Context, *bun.DB) error) *Server {
+	t.Helper()
+	cfg.DBPath = filepath.Join(t.TempDir(), "test.db")
+	sqlDB, err := sql.Open("sqlite", assets.DSN(cfg.DBPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := bun.NewDB(sqlDB, sqlitedialect.New())
+	assets.RegisterModels(db)
+	ctx := context.Background()
+	err = assets.InitDB(ctx, db)
+	if err == nil && seed != nil {
+		err = seed(ctx, db)
+	}
+	if closeErr := db.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		t.Fatalf("seeding database error = %v", err)
+	}
+	server, err := NewServer(cfg, nil)
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	t.Cleanup(func() { _ = server.db.Close() })
+
+	return server
+}
+
+// serve returns the response of s to a GET request for target.
+func serve(s http.Handler, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+
+	return rec
+}
+
+func TestHandlersPublication(t *testing.T) {
+	now := time.Now()
+	server := newTestServer(t, DefaultConfig(), func(ctx context.Context, db *bun.DB) error {
+		for _, post := range []*assets.Post{
+			{Slug: "live", Title: "Live Post"},
+			{Slug: "draft", Title: "Draft Post", Draft: true},
+			{
+				Slug:      "scheduled",
+				Title:     "Scheduled Post",
+				PublishAt: &assets.CustomTime{Time: now.Add(time.Hour)},
+			},
+			{Slug: "unlisted", Title: "Unlisted Post", Unlisted: true},
+		} {
+			post.CreatedAt = assets.CustomTime{Time: now.Add(-time.Hour)}
+			post.UpdatedAt = post.CreatedAt
+			if _, err := assets.UpsertPost(ctx, db, post); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	tests := []struct {
+		target      string
+		wantStatus  int
+		wantTitles  []string
+		wantMissing []string
+	}{
+		{target: "/post/live", wantStatus: http.StatusOK, wantTitles: []string{"Live Post"}},
+		{target: "/post/draft", wantStatus: http.StatusNotFound, wantMissing: []string{"Draft Post"}},
+		{target: "/post/scheduled", wantStatus: http.StatusNotFound, wantMissing: []string{"Scheduled Post"}},
+		{target: "/post/unlisted", wantStatus: http.StatusOK, wantTitles: []string{"Unlisted Post"}},
+		{
+			target:      "/posts",
+			wantStatus:  http.StatusOK,
+			wantTitles:  []string{"Live Post"},
+			wantMissing: []string{"Draft Post", "Scheduled Post", "Unlisted Post"},
+		},
+		{
+			target:      "/search/posts?search=post",
+			wantStatus:  http.StatusOK,
+			wantTitles:  []string{"Live Post"},
+			wantMissing: []string{"Draft Post", "Scheduled Post", "Unlisted Post"},
+		},
+		{
+			target:      "/",
+			wantStatus:  http.StatusOK,
+			wantTitles:  []string{"Live Post"},
+			wantMissing: []string{"Draft Post", "Scheduled Post", "Unlisted Post"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			rec := serve(server, tt.target)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			body := rec.Body.String()
+			for _, title := range tt.wantTitles {
+				if !strings.Contains(body, title) {
+					t.Errorf("body is missing %q", title)
+				}
+			}
+			for _, title := range tt.wantMissing {
+				if strings.Contains(body, title) {
+					t.Errorf("body contains %q", title)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package conneroh
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/conneroisu/conneroh.com/internal/logger"
+	"github.com/conneroisu/conneroh.com/internal/metrics"
+	"github.com/conneroisu/conneroh.com/internal/routing"
+)
+
+// readyTimeout bounds the database ping of a readiness check.
+const readyTimeout = 2 * time.Second
+
+// BuildInfo describes the running binary and the content it serves.
+type BuildInfo struct {
+	Module          string     `json:"module"`
+	Version         string     `json:"version"`
+	Revision        string     `json:"revision,omitempty"`
+	RevisionTime    string     `json:"revision_time,omitempty"`
+	Modified        bool       `json:"modified,omitempty"`
+	GoVersion       string     `json:"go_version"`
+	ContentVersion  string     `json:"content_version"`
+	ContentSyncedAt *time.Time `json:"content_synced_at,omitempty"`
+}
+
+// newProbes returns the routes reporting the health of s, along with its
+// metrics and the log level endpoint if enabled. They are served ahead of the
+// middleware chain, so frequent probes and scrapes are neither logged nor
+// kept waiting by a content reload.
+func (s *Server) newProbes() *http.ServeMux {
+	probes := http.NewServeMux()
+	probes.HandleFunc("GET /healthz", s.handleHealthz)
+	probes.HandleFunc("GET /readyz", s.handleReadyz)
+	probes.HandleFunc("GET /version", s.handleVersion)
+	if s.cfg.Metrics {
+		probes.Handle("GET /metrics", routing.Chain(
+			metrics.Handler(),
+			routing.BearerAuth(s.cfg.MetricsToken, "metrics"),
+		))
+	}
+	// The level can only be changed by those holding the admin token.
+	if s.cfg.AdminToken != "" && s.logLevel != nil {
+		probes.Handle("/admin/log-level", routing.Chain(
+			logger.LevelHandler(s.logLevel),
+			routing.BearerAuth(s.cfg.AdminToken, "admin"),
+		))
+	}
+
+	return probes
+}
+
+// handleHealthz reports that the process is alive.
+func (s *Server) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+// handleReadyz reports whether the server can serve pages: it is not
+// shutting down, the database answers and content has been loaded from it.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	if s.draining.Load() {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+	err := s.db.PingContext(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "readiness check failed", "err", err)
+		http.Error(w, "database unavailable", http.StatusServiceUnavailable)
+
+		return
+	}
+	if s.site.Load().version == "" {
+		http.Error(w, "no content loaded", http.StatusServiceUnavailable)
+
+		return
+	}
+	_, _ = w.Write([]byte("ready\n"))
+}
+
+// handleVersion reports the build and content versions as JSON.
+func (s *Server) handleVersion(w http.ResponseWriter, _ *http.Request) {
+	info := readBuildInfo()
+	site := s.site.Load()
+	info.ContentVersion = site.version
+	if !site.syncedAt.IsZero() {
+		info.ContentSyncedAt = &site.syncedAt
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(info)
+}
+
+// drain makes readiness checks fail, then gives the proxy the configured
+// drain delay to notice before the caller shuts the server down.
+func (s *Server) drain() {
+	s.draining.Store(true)
+	slog.Info("reporting not ready before shutdown", slog.Duration("delay", s.cfg.DrainDelay))
+	time.Sleep(s.cfg.DrainDelay)
+}
+
+// readBuildInfo returns the module and VCS information embedded by the Go
+// toolchain.
+func readBuildInfo() BuildInfo {
+	info := BuildInfo{Version: "unknown"}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	info.Module = bi.Main.Path
+	info.Version = bi.Main.Version
+	info.GoVersion = bi.GoVersion
+	for _, setting := range bi.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info.Revision = setting.Value
+		case "vcs.time":
+			info.RevisionTime = setting.Value
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+
+	return info
+}
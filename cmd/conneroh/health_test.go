@@ -0,0 +1,130 @@
+package conneroh
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/conneroisu/conneroh.com/internal/assets"
+	"github.com/uptrace/bun"
+)
+
+// seedContent records a processed source file, as cmd/update does, so that
+// the server has content loaded.
+func seedContent(ctx context.Context, db *bun.DB) error {
+	_, err := db.NewInsert().
+		Model(&assets.Cache{Path: "posts/hello.md", Hash: "hash"}).
+		Exec(ctx)
+
+	return err
+}
+
+func TestReadyz(t *testing.T) {
+	tests := []struct {
+		name       string
+		seed       func(context.Context, *bun.DB) error
+		draining   bool
+		wantStatus int
+	}{
+		{name: "Ready", seed: seedContent, wantStatus: http.StatusOK},
+		{name: "No content loaded", wantStatus: http.StatusServiceUnavailable},
+		{name: "Draining", seed: seedContent, draining: true, wantStatus: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.DrainDelay = 0
+			server := newTestServer(t, cfg, tt.seed)
+			if rec := serve(server, "/healthz"); rec.Code != http.StatusOK {
+				t.Errorf("/healthz status = %d, want %d", rec.Code, http.StatusOK)
+			}
+			if tt.draining {
+				server.drain()
+			}
+			rec := serve(server, "/readyz")
+			if rec.Code != tt.wantStatus {
+				t.Errorf("/readyz status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+				t.Errorf("/readyz Cache-Control = %q, want no-store", got)
+			}
+		})
+	}
+}
+
+func TestVersion(t *testing.T) {
+	server := newTestServer(t, DefaultConfig(), seedContent)
+	rec := serve(server, "/version")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("/version status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("/version Content-Type = %q, want application/json", got)
+	}
+	var info BuildInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatalf("/version body %s error = %v", rec.Body, err)
+	}
+	site := server.site.Load()
+	if info.ContentVersion == "" || info.ContentVersion != site.version {
+		t.Errorf("ContentVersion = %q, want %q", info.ContentVersion, site.version)
+	}
+	if info.ContentSyncedAt == nil || !info.ContentSyncedAt.Equal(site.syncedAt) {
+		t.Errorf("ContentSyncedAt = %v, want %v", info.ContentSyncedAt, site.syncedAt)
+	}
+	if info.GoVersion != runtime.Version() {
+		t.Errorf("GoVersion = %q, want %q", info.GoVersion, runtime.Version())
+	}
+	if info.Version == "" {
+		t.Error("Version is empty")
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	tests := []struct {
+		name       string
+		metrics    bool
+		token      string
+		auth       string
+		wantStatus int
+	}{
+		{name: "Disabled", wantStatus: http.StatusNotFound},
+		{name: "Enabled", metrics: true, wantStatus: http.StatusOK},
+		{name: "Token", metrics: true, token: "secret", auth: "Bearer secret", wantStatus: http.StatusOK},
+		{name: "Missing token", metrics: true, token: "secret", wantStatus: http.StatusUnauthorized},
+		{name: "Wrong token", metrics: true, token: "secret", auth: "Bearer guess", wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Metrics = tt.metrics
+			cfg.MetricsToken = tt.token
+			server := newTestServer(t, cfg, seedContent)
+			serve(server, "/posts")
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("/metrics status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+			for _, want := range []string{
+				`conneroh_http_requests_total{route="GET /posts",status="200"}`,
+				`conneroh_http_request_duration_seconds_bucket{route="GET /posts",status="200",le="+Inf"}`,
+			} {
+				if !strings.Contains(rec.Body.String(), want) {
+					t.Errorf("/metrics is missing %s", want)
+				}
+			}
+		})
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
-	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -17,6 +16,8 @@ import (
 	classes "github.com/conneroisu/conneroh.com/cmd/conneroh/classes"
 	"github.com/conneroisu/conneroh.com/internal/assets"
 	"github.com/conneroisu/conneroh.com/internal/logger"
+	"github.com/conneroisu/conneroh.com/internal/metrics"
+	"github.com/conneroisu/conneroh.com/internal/tracing"
 	"github.com/rotisserie/eris"
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect/sqlitedialect"
@@ -26,62 +27,89 @@ import (
 	_ "modernc.org/sqlite"
 )
 
-const (
-	defaultHost       = "0.0.0.0"
-	defaultPort       = "8080"
-	shutdownTimeout   = 10 * time.Second
-	readTimeout       = 15 * time.Second
-	writeTimeout      = 15 * time.Second
-	idleTimeout       = 60 * time.Second
-	readHeaderTimeout = 5 * time.Second
-)
-
-// NewServer creates a new server.
-func NewServer() (http.Handler, error) {
+// NewServer creates a new server configured by cfg. If logLevel is not nil,
+// the admin endpoint changes it.
+func NewServer(cfg Config, logLevel *slog.LevelVar) (*Server, error) {
 	classes.SetCache()
-	slog.SetDefault(logger.DefaultLogger)
-	mux := http.NewServeMux()
-	sqlDB, err := sql.Open("sqlite", assets.DBName())
+	sqlDB, err := sql.Open("sqlite", assets.DSN(cfg.DBPath))
 	if err != nil {
 		return nil, eris.Wrap(err, "error opening database")
 	}
 	db := bun.NewDB(sqlDB, sqlitedialect.New())
 	assets.RegisterModels(db)
+	err = assets.InitDB(context.Background(), db)
+	if err != nil {
+		return nil, eris.Wrap(err, "error migrating database")
+	}
+	if cfg.LocalAssets {
+		assets.UseLocalAssets()
+	} else {
+		err = assets.SetAssetBaseURL(cfg.AssetBaseURL)
+		if err != nil {
+			return nil, eris.Wrap(err, "error configuring assets")
+		}
+	}
 
-	if os.Getenv("DEBUG") == "true" {
+	db.AddQueryHook(metrics.QueryHook{})
+	db.AddQueryHook(tracing.QueryHook{})
+	if cfg.Debug {
 		db.AddQueryHook(bundebug.NewQueryHook(bundebug.WithVerbose(true)))
 	}
-	err = AddRoutes(mux, db)
+	if cfg.Preview {
+		slog.Info("preview mode enabled, drafts and scheduled documents are visible")
+		previewMode = true
+	}
+	policy, err := securityPolicy(os.Getenv)
 	if err != nil {
-		return nil, eris.Wrap(err, "error adding routes")
+		return nil, eris.Wrap(err, "error configuring security headers")
+	}
+	server := &Server{
+		cfg:      cfg,
+		db:       db,
+		policy:   policy,
+		logLevel: logLevel,
+	}
+	server.probes = server.newProbes()
+	err = server.reload(context.Background())
+	if err != nil {
+		return nil, err
 	}
-	slogLogHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		slog.Info(
-			"request",
-			slog.String("method", r.Method),
-			slog.String("url", r.URL.String()),
-		)
-
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-		mux.ServeHTTP(w, r)
-	})
-	var handler http.Handler = slogLogHandler
 
-	return handler, nil
+	return server, nil
 }
 
-// Run is the entry point for the application.
+// Run is the entry point for the application, configured by the flags in
+// args and the environment.
 func Run(
 	ctx context.Context,
-	_ func(string) string,
+	args []string,
+	getenv func(string) string,
 ) error {
 	var wg sync.WaitGroup
 
 	start := time.Now()
 
+	cfg, err := LoadConfig(args, getenv)
+	if err != nil {
+		return eris.Wrap(err, "invalid configuration")
+	}
+	if cfg.PrintConfig {
+		return cfg.Print(os.Stdout)
+	}
+	baseLevel, _ := cfg.Level()
+	level := new(slog.LevelVar)
+	level.Set(baseLevel)
+	log, err := logger.New(os.Stdout, logger.Options{
+		Format:     cfg.LogFormat,
+		Level:      level,
+		Timestamps: cfg.LogTimestamps,
+	})
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(log)
+	slog.Info("configuration loaded", slog.Any("config", cfg))
+
 	// Create a separate context for signal handling
 	innerCtx, cancel := signal.NotifyContext(
 		context.Background(), // Use a fresh context instead of the parent ctx
@@ -92,62 +120,74 @@ func Run(
 		syscall.SIGQUIT,
 	)
 	defer cancel()
+	go logger.ToggleDebugOn(innerCtx, level, baseLevel, syscall.SIGUSR1)
 
-	handler, err := NewServer()
+	shutdownTracing, err := tracing.Setup(ctx, getenv, "conneroh")
 	if err != nil {
-		return err
+		return eris.Wrap(err, "error setting up tracing")
 	}
-
-	// Configure server with timeouts
-	httpServer := &http.Server{
-		Addr: net.JoinHostPort(
-			defaultHost,
-			defaultPort,
-		),
-		Handler:           handler,
-		ReadTimeout:       readTimeout,
-		WriteTimeout:      writeTimeout,
-		IdleTimeout:       idleTimeout,
-		ReadHeaderTimeout: readHeaderTimeout,
-	}
-
-	serverErrors := make(chan error, 1)
-
-	// Start server
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		slog.Info(
-			"server starting",
-			slog.String("address", httpServer.Addr),
-			slog.String("setup-time", time.Since(start).String()),
-		)
-		err := httpServer.ListenAndServe()
-		if err != nil && !errors.Is(err, http.ErrServerClosed) {
-			serverErrors <- fmt.Errorf("server error: %w", err)
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+		err := shutdownTracing(flushCtx)
+		if err != nil {
+			slog.Error("error flushing traces", slog.String("error", err.Error()))
 		}
 	}()
 
+	handler, err := NewServer(cfg, level)
+	if err != nil {
+		return err
+	}
+	go handler.Watch(innerCtx, cfg.ContentPollInterval)
+
+	listeners := newListeners(cfg, handler)
+	serverErrors := make(chan error, len(listeners))
+
+	// Start servers
+	for _, l := range listeners {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slog.Info(
+				"server starting",
+				slog.String("address", l.server.Addr),
+				slog.Bool("tls", l.server.TLSConfig != nil),
+				slog.String("setup-time", time.Since(start).String()),
+			)
+			err := l.serve()
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serverErrors <- fmt.Errorf("%s: %w", l.server.Addr, err)
+			}
+		}()
+	}
+
 	// Wait for either server error or shutdown signal
 	select {
 	case err := <-serverErrors:
+		// Stop the listeners that did start before reporting the error.
+		_ = gracefulShutdown(listeners, &wg, cfg.ShutdownTimeout)
+
 		return fmt.Errorf("server error: %w", err)
 	case <-innerCtx.Done():
 		// Signal received, initiate graceful shutdown
 		slog.Info("shutdown signal received, shutting down server...")
+		handler.drain()
 
-		return gracefulShutdown(httpServer, &wg)
+		return gracefulShutdown(listeners, &wg, cfg.ShutdownTimeout)
 	case <-ctx.Done():
 		// Parent context cancelled
 		slog.Info("parent context cancelled, shutting down...")
+		handler.drain()
 
-		return gracefulShutdown(httpServer, &wg)
+		return gracefulShutdown(listeners, &wg, cfg.ShutdownTimeout)
 	}
 }
 
 func gracefulShutdown(
-	httpServer *http.Server,
+	listeners []listener,
 	wg *sync.WaitGroup,
+	shutdownTimeout time.Duration,
 ) error {
 	// Create shutdown context with timeout
 	shutdownCtx, cancel := context.WithTimeout(
@@ -156,8 +196,19 @@ func gracefulShutdown(
 	)
 	defer cancel()
 
-	// Attempt graceful shutdown
-	err := httpServer.Shutdown(shutdownCtx)
+	// Attempt graceful shutdown of every listener at once, so that they
+	// share the timeout
+	errs := make([]error, len(listeners))
+	var shutdowns sync.WaitGroup
+	for i, l := range listeners {
+		shutdowns.Add(1)
+		go func() {
+			defer shutdowns.Done()
+			errs[i] = l.server.Shutdown(shutdownCtx)
+		}()
+	}
+	shutdowns.Wait()
+	err := errors.Join(errs...)
 	if err != nil {
 		slog.Error("error during server shutdown",
 			slog.String("error", err.Error()),
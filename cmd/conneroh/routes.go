@@ -3,6 +3,7 @@ package conneroh
 import (
 	"log/slog"
 	"net/http"
+	"sync"
 
 	static "github.com/conneroisu/conneroh.com/cmd/conneroh/_static"
 	"github.com/conneroisu/conneroh.com/cmd/conneroh/layouts"
@@ -13,13 +14,50 @@ import (
 )
 
 var (
-	// Instance Caches.
+	// Instance Caches, guarded by listsMu as requests are served
+	// concurrently and may outlive the routes of a reload.
+	listsMu        sync.Mutex
 	allPosts       = []*assets.Post{}
 	allProjects    = []*assets.Project{}
 	allTags        = []*assets.Tag{}
 	allEmployments = []*assets.Employment{}
+
+	// previewMode shows drafts, scheduled and unlisted documents everywhere.
+	previewMode = false
 )
 
+// loadList returns the documents of list, scanning them with scan and keeping
+// the listed ones if list is empty.
+func loadList[T any](
+	list *[]*T,
+	listed func([]*T) []*T,
+	scan func(*[]*T) error,
+) ([]*T, error) {
+	listsMu.Lock()
+	defer listsMu.Unlock()
+	if len(*list) == 0 {
+		var docs []*T
+		err := scan(&docs)
+		if err != nil {
+			return nil, err
+		}
+		*list = listed(docs)
+	}
+
+	return *list, nil
+}
+
+// resetLists drops the documents of every list, so that they are scanned
+// again.
+func resetLists() {
+	listsMu.Lock()
+	defer listsMu.Unlock()
+	allPosts = []*assets.Post{}
+	allProjects = []*assets.Project{}
+	allTags = []*assets.Tag{}
+	allEmployments = []*assets.Employment{}
+}
+
 // AddRoutes adds all routes to the router.
 func AddRoutes(
 	h *http.ServeMux,
@@ -31,6 +69,14 @@ func AddRoutes(
 	h.Handle(
 		"GET /dist/",
 		http.FileServer(http.FS(static.Dist)))
+	if assets.LocalAssets() {
+		slog.Info("serving assets from the vault")
+		h.Handle(
+			"GET /"+assets.AssetsLoc,
+			http.StripPrefix(
+				"/"+assets.AssetsLoc,
+				routing.FileServer(http.Dir(assets.VaultLoc+assets.AssetsLoc))))
+	}
 
 	h.HandleFunc(
 		"GET /{$}",
@@ -47,6 +93,15 @@ func AddRoutes(
 	h.HandleFunc(
 		"GET /post/{slug...}",
 		routing.Make(HandlePost(db)))
+	h.HandleFunc(
+		"GET /diff/post/{slug...}",
+		routing.Make(HandlePostDiff(db)))
+	h.HandleFunc(
+		"GET /series/{slug...}",
+		routing.Make(HandleSeries(db)))
+	h.HandleFunc(
+		"GET /media/{slug...}",
+		routing.Make(HandleMedia(db)))
 	h.HandleFunc(
 		"GET /projects",
 		routing.Make(HandleProjects(db)))
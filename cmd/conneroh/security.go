@@ -0,0 +1,42 @@
+package conneroh
+
+import (
+	"github.com/conneroisu/conneroh.com/cmd/conneroh/components"
+	"github.com/conneroisu/conneroh.com/internal/routing"
+)
+
+const (
+	// utterancesOrigin serves the comment widget on documents.
+	utterancesOrigin = "https://utteranc.es"
+	// jsDelivrOrigin serves MathJax and mermaid for content rendered in the
+	// browser.
+	jsDelivrOrigin = "https://cdn.jsdelivr.net"
+)
+
+// securityPolicy returns the security headers of the site, configured from
+// the environment.
+//
+// Inline scripts are allowed by their hashes rather than a nonce, so that
+// cached pages stay valid. Alpine.js and hx-on evaluate expressions, so
+// scripts need 'unsafe-eval'; Alpine transitions, MathJax and utterances set
+// inline styles. Images and media come from the asset base URL or, in
+// content, from anywhere else.
+func securityPolicy(getenv func(string) string) (routing.SecurityPolicy, error) {
+	policy := routing.DefaultSecurityPolicy()
+	policy.CSP.Remove("script-src", routing.NonceSource)
+	policy.CSP.Add("script-src", "'unsafe-eval'", utterancesOrigin, jsDelivrOrigin)
+	for _, script := range components.InlineScripts {
+		policy.CSP.Add("script-src", routing.HashSource(script))
+	}
+	policy.CSP.Add("style-src", "'unsafe-inline'")
+	policy.CSP.Add("img-src", "https:")
+	policy.CSP.Add("media-src", "'self'", "https:")
+	policy.CSP.Add("font-src", "'self'", "data:", jsDelivrOrigin)
+	policy.CSP.Add("frame-src", utterancesOrigin)
+	err := policy.Configure(getenv)
+	if err != nil {
+		return routing.SecurityPolicy{}, err
+	}
+
+	return policy, nil
+}
@@ -0,0 +1,199 @@
+package conneroh
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/conneroisu/conneroh.com/internal/assets"
+	"github.com/conneroisu/conneroh.com/internal/routing"
+	"github.com/rotisserie/eris"
+	"github.com/uptrace/bun"
+)
+
+// Server serves the site from the database, caching rendered responses until
+// the content in the database changes.
+type Server struct {
+	cfg    Config
+	db     *bun.DB
+	policy routing.SecurityPolicy
+	probes *http.ServeMux
+
+	// logLevel is the level of the default logger, changed through the
+	// admin endpoint. It is nil if the level is fixed.
+	logLevel *slog.LevelVar
+
+	// draining is set once the server is shutting down.
+	draining atomic.Bool
+
+	// reloading serializes reloads, which share the media registry and
+	// document lists.
+	reloading sync.Mutex
+	// site is swapped whole on every reload, so requests never wait for one
+	// and requests still rendering the old content cannot cache it anew.
+	site atomic.Pointer[site]
+}
+
+// site is a version of the content with the routes and response cache
+// serving it.
+type site struct {
+	handler http.Handler
+	// version fingerprints the loaded content, "" if there is none.
+	version  string
+	syncedAt time.Time
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if probe, pattern := s.probes.Handler(r); pattern != "" {
+		probe.ServeHTTP(w, r)
+
+		return
+	}
+	s.site.Load().handler.ServeHTTP(w, r)
+}
+
+// newHandler wraps mux with the middleware of the site and a response cache
+// of its own.
+func (s *Server) newHandler(mux *http.ServeMux) http.Handler {
+	return routing.Chain(
+		mux,
+		routing.RequestIDs,
+		routing.ResolveRoute(func(r *http.Request) string {
+			_, pattern := mux.Handler(r)
+
+			return pattern
+		}),
+		routing.Trace,
+		routing.AccessLog,
+		routing.Instrument,
+		routing.Timing,
+		routing.Recover(http.HandlerFunc(handlePanic)),
+		routing.NewResponseCache().Wrap,
+		// Security headers are set before responses are cached, so that a
+		// cached page is served with the CSP it was rendered under.
+		s.policy.Wrap,
+	)
+}
+
+// Watch checks the database for new content every interval until ctx is
+// done, reloading the server when it finds some.
+func (s *Server) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		version, err := contentVersion(ctx, s.db)
+		if err != nil {
+			slog.Error("failed to check content version", "err", err)
+
+			continue
+		}
+		if version == s.site.Load().version {
+			continue
+		}
+		slog.Info("content changed, reloading", "version", version)
+		err = s.reload(ctx)
+		if err != nil {
+			slog.Error("failed to reload content", "err", err)
+		}
+	}
+}
+
+// reload drops every cached page, document and response and rebuilds the
+// routes, whose handlers cache the documents they render.
+func (s *Server) reload(ctx context.Context) error {
+	s.reloading.Lock()
+	defer s.reloading.Unlock()
+	version, err := contentVersion(ctx, s.db)
+	if err != nil {
+		return err
+	}
+	err = assets.LoadMedia(ctx, s.db)
+	if err != nil {
+		return eris.Wrap(err, "error loading media")
+	}
+	resetLists()
+	mux := http.NewServeMux()
+	err = AddRoutes(mux, s.db)
+	if err != nil {
+		return eris.Wrap(err, "error adding routes")
+	}
+	s.site.Store(&site{
+		handler:  s.newHandler(mux),
+		version:  version,
+		syncedAt: time.Now().UTC(),
+	})
+
+	return nil
+}
+
+// contentVersion fingerprints the content of the database by the hashes
+// cmd/update records for every source file it processed and by the scheduled
+// documents already live, returning "" if it has processed none. A scheduled
+// document going live thus changes the version, reloading the server.
+func contentVersion(ctx context.Context, db *bun.DB) (string, error) {
+	var hashes []string
+	err := db.NewSelect().
+		Model((*assets.Cache)(nil)).
+		Column("hashed").
+		Order("path").
+		Scan(ctx, &hashes)
+	if err != nil {
+		return "", eris.Wrap(err, "failed to read content hashes")
+	}
+	if len(hashes) == 0 {
+		return "", nil
+	}
+	live, err := liveScheduled(ctx, db, time.Now())
+	if err != nil {
+		return "", err
+	}
+	hashes = append(hashes, strconv.Itoa(live))
+
+	return assets.ComputeHash([]byte(strings.Join(hashes, ""))), nil
+}
+
+// liveScheduled counts the scheduled posts and projects published by now.
+func liveScheduled(ctx context.Context, db *bun.DB, now time.Time) (int, error) {
+	var (
+		posts    []*assets.Post
+		projects []*assets.Project
+		live     int
+	)
+	err := db.NewSelect().Model(&posts).
+		Column("draft", "publish_at").
+		Where("publish_at IS NOT NULL").
+		Scan(ctx)
+	if err != nil {
+		return 0, eris.Wrap(err, "failed to read post schedules")
+	}
+	err = db.NewSelect().Model(&projects).
+		Column("draft", "publish_at").
+		Where("publish_at IS NOT NULL").
+		Scan(ctx)
+	if err != nil {
+		return 0, eris.Wrap(err, "failed to read project schedules")
+	}
+	for _, post := range posts {
+		if post.IsPublished(now) {
+			live++
+		}
+	}
+	for _, project := range projects {
+		if project.IsPublished(now) {
+			live++
+		}
+	}
+
+	return live, nil
+}
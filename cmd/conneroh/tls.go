@@ -0,0 +1,101 @@
+package conneroh
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// listener is a server Run starts and shuts down.
+type listener struct {
+	server *http.Server
+	// serve runs server until it is shut down.
+	serve func() error
+}
+
+// newListeners returns the servers handling the site as cfg describes: the
+// site itself, over TLS if configured, and the listener redirecting HTTP to
+// it if enabled.
+func newListeners(cfg Config, handler http.Handler) []listener {
+	site := &http.Server{
+		Addr:              cfg.Addr(),
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		Protocols:         new(http.Protocols),
+	}
+	site.Protocols.SetHTTP1(true)
+	site.Protocols.SetHTTP2(true)
+	// A proxy terminating TLS in front of the server may speak HTTP/2 to
+	// it in cleartext.
+	site.Protocols.SetUnencryptedHTTP2(cfg.H2C)
+
+	if !cfg.TLSEnabled() {
+		return []listener{{server: site, serve: site.ListenAndServe}}
+	}
+
+	var redirect http.Handler = redirectToHTTPS(cfg.Port)
+	listeners := []listener{{
+		server: site,
+		serve: func() error {
+			return site.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+		},
+	}}
+	if hosts := cfg.ACMEHostList(); len(hosts) > 0 {
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			Cache:      autocert.DirCache(cfg.ACMECacheDir),
+			HostPolicy: autocert.HostWhitelist(hosts...),
+			Email:      cfg.ACMEEmail,
+		}
+		site.TLSConfig = manager.TLSConfig()
+		// The redirect listener also answers the HTTP-01 challenges of the
+		// certificate authority.
+		redirect = manager.HTTPHandler(redirect)
+	} else {
+		site.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if cfg.RedirectPort != 0 {
+		srv := &http.Server{
+			Addr:              net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.RedirectPort)),
+			Handler:           redirect,
+			ReadTimeout:       cfg.ReadTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		}
+		listeners = append(listeners, listener{server: srv, serve: srv.ListenAndServe})
+	}
+
+	return listeners
+}
+
+// redirectToHTTPS permanently redirects every request to the same URL over
+// HTTPS on port.
+func redirectToHTTPS(port int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.Trim(host, "[]")
+		if host == "" {
+			http.Error(w, "missing host", http.StatusBadRequest)
+
+			return
+		}
+		switch {
+		case port != 443:
+			host = net.JoinHostPort(host, strconv.Itoa(port))
+		case strings.Contains(host, ":"):
+			host = "[" + host + "]"
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
@@ -0,0 +1,80 @@
+package conneroh
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		name         string
+		port         int
+		host         string
+		target       string
+		wantStatus   int
+		wantLocation string
+	}{
+		{
+			name:         "Default port",
+			port:         443,
+			host:         "conneroh.com",
+			target:       "/posts?page=2",
+			wantStatus:   http.StatusMovedPermanently,
+			wantLocation: "https://conneroh.com/posts?page=2",
+		},
+		{
+			name:         "HTTP port is dropped",
+			port:         443,
+			host:         "conneroh.com:80",
+			target:       "/",
+			wantStatus:   http.StatusMovedPermanently,
+			wantLocation: "https://conneroh.com/",
+		},
+		{
+			name:         "Other port",
+			port:         8443,
+			host:         "conneroh.com:8080",
+			target:       "/post/fpga",
+			wantStatus:   http.StatusMovedPermanently,
+			wantLocation: "https://conneroh.com:8443/post/fpga",
+		},
+		{
+			name:         "IPv6 on the default port",
+			port:         443,
+			host:         "[::1]:80",
+			target:       "/",
+			wantStatus:   http.StatusMovedPermanently,
+			wantLocation: "https://[::1]/",
+		},
+		{
+			name:         "IPv6 on another port",
+			port:         8443,
+			host:         "[::1]",
+			target:       "/tags",
+			wantStatus:   http.StatusMovedPermanently,
+			wantLocation: "https://[::1]:8443/tags",
+		},
+		{
+			name:       "Missing host",
+			port:       443,
+			host:       "",
+			target:     "/",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.Host = tt.host
+			rec := httptest.NewRecorder()
+			redirectToHTTPS(tt.port).ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("redirectToHTTPS(%d) status = %d, want %d", tt.port, rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("redirectToHTTPS(%d) Location = %q, want %q", tt.port, got, tt.wantLocation)
+			}
+		})
+	}
+}
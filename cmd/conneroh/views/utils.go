@@ -16,3 +16,29 @@ func readTime(content string) string {
 
 	return strconv.Itoa(minutes)
 }
+
+// readingTime returns the reading time persisted by cmd/update, estimating it
+// from the content for documents stored before it was computed.
+func readingTime(minutes int, content string) string {
+	if minutes > 0 {
+		return strconv.Itoa(minutes)
+	}
+
+	return readTime(content)
+}
+
+// byteSize formats a size in bytes with a binary unit, e.g. "1.5 KiB".
+func byteSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return strconv.FormatFloat(float64(n)/float64(div), 'f', 1, 64) +
+		" " + string("KMGTPE"[exp]) + "iB"
+}
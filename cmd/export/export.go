@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	static "github.com/conneroisu/conneroh.com/cmd/conneroh/_static"
+	"github.com/conneroisu/conneroh.com/internal/assets"
+	"github.com/conneroisu/conneroh.com/internal/routing"
+	"github.com/rotisserie/eris"
+	"golang.org/x/net/html"
+)
+
+const (
+	// pageFile is the file a page is written to inside its directory.
+	pageFile = "index.html"
+	// fragmentFile is the file the htmx fragment of a page is written to,
+	// next to the page.
+	fragmentFile = "fragment.html"
+)
+
+// linkAttrs are the attributes holding URLs to follow and rewrite.
+var linkAttrs = []string{"href", "src", "poster", "hx-get"}
+
+// target is a URL to export, either as a full page or as the fragment htmx
+// swaps in.
+type target struct {
+	url      string
+	fragment bool
+}
+
+// exporter crawls the site from a set of seed URLs, rendering every page it
+// reaches through the server's own handler and rewriting the links between
+// them to the static files they are written to.
+type exporter struct {
+	handler http.Handler
+	out     string
+	base    string
+
+	seen   map[target]bool
+	queue  []target
+	from   map[target]string
+	broken []string
+
+	pages int
+	files int
+}
+
+func newExporter(handler http.Handler, out, base string) *exporter {
+	if base = strings.Trim(base, "/"); base != "" {
+		base += "/"
+	}
+
+	return &exporter{
+		handler: handler,
+		out:     out,
+		base:    "/" + base,
+		seen:    map[target]bool{},
+		from:    map[target]string{},
+	}
+}
+
+// Export renders every page reachable from seeds to the output directory and
+// fails if any of them fails to render or links to a page that does not
+// exist.
+func (e *exporter) Export(ctx context.Context, seeds []string) error {
+	for _, seed := range seeds {
+		e.enqueue(target{url: seed}, "seed")
+	}
+	for len(e.queue) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		next := e.queue[0]
+		e.queue = e.queue[1:]
+		err := e.export(next)
+		if err != nil {
+			return err
+		}
+	}
+	if len(e.broken) > 0 {
+		slices.Sort(e.broken)
+
+		return eris.Errorf(
+			"%d broken links:\n  %s",
+			len(e.broken),
+			strings.Join(e.broken, "\n  "),
+		)
+	}
+
+	return nil
+}
+
+// enqueue schedules t to be exported unless it already was.
+func (e *exporter) enqueue(t target, from string) {
+	if e.seen[t] {
+		return
+	}
+	e.seen[t] = true
+	e.from[t] = from
+	e.queue = append(e.queue, t)
+}
+
+// fail records that t could not be exported.
+func (e *exporter) fail(t target, reason string) {
+	kind := "link"
+	if t.fragment {
+		kind = "fragment"
+	}
+	e.broken = append(e.broken, fmt.Sprintf("%s %s (from %s): %s", kind, t.url, e.from[t], reason))
+}
+
+// export writes t to the output directory.
+func (e *exporter) export(t target) error {
+	u, err := url.Parse(t.url)
+	if err != nil {
+		e.fail(t, err.Error())
+
+		return nil
+	}
+	if p, ok := strings.CutPrefix(u.Path, "/dist/"); ok {
+		return e.copyFile(t, static.Dist, "dist/"+p, u.Path)
+	}
+	if p, ok := strings.CutPrefix(u.Path, "/"+assets.AssetsLoc); ok && assets.LocalAssets() {
+		return e.copyFile(t, os.DirFS(assets.VaultLoc), assets.AssetsLoc+p, u.Path)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, u.String(), nil)
+	if t.fragment {
+		req.Header.Set(routing.HdrRequest, "true")
+	}
+	rec := httptest.NewRecorder()
+	e.handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		e.fail(t, strconv.Itoa(rec.Code)+" "+strings.TrimSpace(rec.Body.String()))
+
+		return nil
+	}
+	file := filePath(u, t.fragment)
+	body, err := e.rewrite(rec.Body.Bytes(), t.url)
+	if err != nil {
+		return eris.Wrapf(err, "failed to rewrite links of %s", t.url)
+	}
+	e.pages++
+
+	return e.write(file, body)
+}
+
+// copyFile copies a static file the site links to.
+func (e *exporter) copyFile(t target, fsys fs.FS, name, file string) error {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		e.fail(t, err.Error())
+
+		return nil
+	}
+	e.files++
+
+	return e.write(strings.TrimPrefix(file, "/"), data)
+}
+
+// write writes a file to the output directory.
+func (e *exporter) write(file string, data []byte) error {
+	dst := filepath.Join(e.out, filepath.FromSlash(file))
+	err := os.MkdirAll(filepath.Dir(dst), 0o755)
+	if err != nil {
+		return eris.Wrapf(err, "failed to create directory for %s", file)
+	}
+	err = os.WriteFile(dst, data, 0o644)
+	if err != nil {
+		return eris.Wrapf(err, "failed to write %s", file)
+	}
+
+	return nil
+}
+
+// rewrite points the internal links of a page at the files they are
+// exported to and schedules them to be exported in turn.
+//
+// Only the tags holding internal links are re-encoded; everything else is
+// copied as it was rendered.
+func (e *exporter) rewrite(body []byte, from string) ([]byte, error) {
+	var buf bytes.Buffer
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				return buf.Bytes(), nil
+			}
+
+			return nil, z.Err()
+		case html.StartTagToken, html.SelfClosingTagToken:
+			raw := slices.Clone(z.Raw())
+			tok := z.Token()
+			if e.rewriteTag(&tok, from) {
+				buf.WriteString(tok.String())
+			} else {
+				buf.Write(raw)
+			}
+		default:
+			buf.Write(z.Raw())
+		}
+	}
+}
+
+// rewriteTag rewrites the internal links of a tag, returning false if it has
+// none.
+func (e *exporter) rewriteTag(tok *html.Token, from string) bool {
+	var (
+		changed bool
+		page    string
+		pushed  string
+	)
+	for i, attr := range tok.Attr {
+		if !slices.Contains(linkAttrs, attr.Key) || !isInternal(attr.Val) {
+			continue
+		}
+		t := target{url: attr.Val, fragment: attr.Key == "hx-get"}
+		e.enqueue(target{url: stripHash(t.url), fragment: t.fragment}, from)
+		tok.Attr[i].Val = e.link(t)
+		if t.fragment {
+			page = e.link(target{url: t.url})
+			pushed = t.url
+		}
+		changed = true
+	}
+	// htmx would push the fragment's URL into the history, which a reload
+	// would then show without the page around it.
+	for i, attr := range tok.Attr {
+		if page != "" && attr.Key == "hx-push-url" && attr.Val == "true" {
+			tok.Attr[i].Val = page
+			e.enqueue(target{url: stripHash(pushed)}, from)
+		}
+	}
+
+	return changed
+}
+
+// link returns the URL of the exported file of t under the base path.
+func (e *exporter) link(t target) string {
+	u, err := url.Parse(t.url)
+	if err != nil {
+		return t.url
+	}
+	file := filePath(u, t.fragment)
+	switch {
+	case strings.HasPrefix(u.Path, "/dist/"),
+		strings.HasPrefix(u.Path, "/"+assets.AssetsLoc):
+		file = strings.TrimPrefix(u.Path, "/")
+	case !t.fragment:
+		// Static hosts serve the index of a directory at its path.
+		file = strings.TrimSuffix(file, pageFile)
+	}
+	link := (&url.URL{Path: e.base + file}).EscapedPath()
+	if u.Fragment != "" {
+		link += "#" + u.EscapedFragment()
+	}
+
+	return link
+}
+
+// filePath returns the path of the file a page is exported to, relative to
+// the output directory. Query strings become directories, so that every page
+// of a paginated list gets its own file.
+func filePath(u *url.URL, fragment bool) string {
+	dir := strings.Trim(u.Path, "/")
+	if q := u.Query(); len(q) > 0 {
+		var parts []string
+		for key, values := range q {
+			for _, value := range values {
+				parts = append(parts, sanitize(key)+"-"+sanitize(value))
+			}
+		}
+		slices.Sort(parts)
+		dir = path.Join(dir, strings.Join(parts, "_"))
+	}
+	if fragment {
+		return path.Join(dir, fragmentFile)
+	}
+
+	return path.Join(dir, pageFile)
+}
+
+// sanitize replaces the characters of a query string key or value that are
+// not safe in a file name.
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9',
+			r == '.', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
+
+// isInternal returns true if link points at a page of the site.
+func isInternal(link string) bool {
+	return strings.HasPrefix(link, "/") && !strings.HasPrefix(link, "//")
+}
+
+// stripHash returns link without its fragment.
+func stripHash(link string) string {
+	link, _, _ = strings.Cut(link, "#")
+
+	return link
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestFilePath(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		fragment bool
+		want     string
+	}{
+		{name: "Home", url: "/", want: "index.html"},
+		{name: "Home fragment", url: "/", fragment: true, want: "fragment.html"},
+		{name: "List", url: "/posts", want: "posts/index.html"},
+		{name: "Nested slug", url: "/post/fpga/part-1", want: "post/fpga/part-1/index.html"},
+		{name: "Query string", url: "/search/posts?page=2", want: "search/posts/page-2/index.html"},
+		{
+			name:     "Sorted query string fragment",
+			url:      "/search/posts?query=go+lang&page=2",
+			fragment: true,
+			want:     "search/posts/page-2_query-go_lang/fragment.html",
+		},
+		{name: "Unsafe query value", url: "/search/tags?query=../etc", want: "search/tags/query-.._etc/index.html"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := filePath(u, tt.fragment); got != tt.want {
+				t.Errorf("filePath(%q, %v) = %q, want %q", tt.url, tt.fragment, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLink(t *testing.T) {
+	tests := []struct {
+		name string
+		base string
+		t    target
+		want string
+	}{
+		{name: "Home", base: "/", t: target{url: "/"}, want: "/"},
+		{name: "Page", base: "/", t: target{url: "/posts"}, want: "/posts/"},
+		{name: "Fragment", base: "/", t: target{url: "/posts", fragment: true}, want: "/posts/fragment.html"},
+		{name: "Hash", base: "/", t: target{url: "/post/fpga#results"}, want: "/post/fpga/#results"},
+		{name: "Query string", base: "/", t: target{url: "/search/posts?page=2"}, want: "/search/posts/page-2/"},
+		{name: "Static file", base: "/", t: target{url: "/dist/index.js"}, want: "/dist/index.js"},
+		{name: "Asset", base: "/", t: target{url: "/assets/a b.png"}, want: "/assets/a%20b.png"},
+		{name: "Base page", base: "/conneroh.com", t: target{url: "/posts"}, want: "/conneroh.com/posts/"},
+		{name: "Base home", base: "/conneroh.com/", t: target{url: "/"}, want: "/conneroh.com/"},
+		{
+			name: "Base query string fragment",
+			base: "conneroh.com",
+			t:    target{url: "/search/posts?page=2", fragment: true},
+			want: "/conneroh.com/search/posts/page-2/fragment.html",
+		},
+		{name: "Base static file", base: "/conneroh.com/", t: target{url: "/dist/index.js"}, want: "/conneroh.com/dist/index.js"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newExporter(nil, t.TempDir(), tt.base)
+			if got := e.link(tt.t); got != tt.want {
+				t.Errorf("link(%+v) = %q, want %q", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewriteTag(t *testing.T) {
+	tests := []struct {
+		name        string
+		base        string
+		tag         string
+		want        string
+		wantChanged bool
+		wantQueued  []target
+	}{
+		{
+			name:        "Link with htmx navigation",
+			base:        "/",
+			tag:         `<a href="/posts" hx-get="/posts" hx-push-url="true" hx-target="#bodiody">`,
+			want:        `<a href="/posts/" hx-get="/posts/fragment.html" hx-push-url="/posts/" hx-target="#bodiody">`,
+			wantChanged: true,
+			wantQueued:  []target{{url: "/posts"}, {url: "/posts", fragment: true}},
+		},
+		{
+			name:        "Paginated htmx request under a base",
+			base:        "/conneroh.com/",
+			tag:         `<button hx-get="/search/posts?page=2" hx-push-url="true">`,
+			want:        `<button hx-get="/conneroh.com/search/posts/page-2/fragment.html" hx-push-url="/conneroh.com/search/posts/page-2/">`,
+			wantChanged: true,
+			wantQueued:  []target{{url: "/search/posts?page=2", fragment: true}, {url: "/search/posts?page=2"}},
+		},
+		{
+			name:        "Hash is kept in links and dropped from exports",
+			base:        "/",
+			tag:         `<a href="/post/fpga#results">`,
+			want:        `<a href="/post/fpga/#results">`,
+			wantChanged: true,
+			wantQueued:  []target{{url: "/post/fpga"}},
+		},
+		{
+			name:        "Asset",
+			base:        "/conneroh.com/",
+			tag:         `<img src="/assets/banner.png" alt="Banner">`,
+			want:        `<img src="/conneroh.com/assets/banner.png" alt="Banner">`,
+			wantChanged: true,
+			wantQueued:  []target{{url: "/assets/banner.png"}},
+		},
+		{
+			name: "Pushed URL without an htmx request",
+			base: "/",
+			tag:  `<div hx-push-url="true">`,
+		},
+		{
+			name: "External links",
+			base: "/",
+			tag:  `<a href="https://github.com/conneroisu" hx-get="//cdn.example.com/x">`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newExporter(nil, t.TempDir(), tt.base)
+			z := html.NewTokenizer(strings.NewReader(tt.tag))
+			z.Next()
+			tok := z.Token()
+			changed := e.rewriteTag(&tok, "/")
+			if changed != tt.wantChanged {
+				t.Errorf("rewriteTag(%s) = %v, want %v", tt.tag, changed, tt.wantChanged)
+			}
+			if tt.wantChanged && tok.String() != tt.want {
+				t.Errorf("rewriteTag(%s) tag = %s, want %s", tt.tag, tok.String(), tt.want)
+			}
+			if !slices.Equal(e.queue, tt.wantQueued) {
+				t.Errorf("rewriteTag(%s) queued %+v, want %+v", tt.tag, e.queue, tt.wantQueued)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+// Package main exports the site to static files.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/conneroisu/conneroh.com/cmd/conneroh"
+	"github.com/conneroisu/conneroh.com/internal/assets"
+	"github.com/rotisserie/eris"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	_ "modernc.org/sqlite"
+)
+
+var (
+	out = flag.String(
+		"out",
+		"public",
+		"directory to write the site to",
+	)
+	base = flag.String(
+		"base",
+		"/",
+		"path the site is hosted under, e.g. /conneroh.com/ for a project page",
+	)
+)
+
+func main() {
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(),
+		os.Interrupt,
+		syscall.SIGTERM,
+		syscall.SIGINT,
+		syscall.SIGHUP)
+	defer stop()
+
+	err := run(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context) error {
+	cfg, err := conneroh.LoadConfig(nil, os.Getenv)
+	if err != nil {
+		return eris.Wrap(err, "invalid configuration")
+	}
+	handler, err := conneroh.NewServer(cfg, nil)
+	if err != nil {
+		return eris.Wrap(err, "failed to create server")
+	}
+	// The server logs every request, which would drown out the export's
+	// own report.
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: slog.LevelWarn,
+	})))
+
+	sqldb, err := sql.Open("sqlite", assets.DSN(cfg.DBPath))
+	if err != nil {
+		return eris.Wrap(err, "failed to open database")
+	}
+	defer sqldb.Close()
+	db := bun.NewDB(sqldb, sqlitedialect.New())
+	assets.RegisterModels(db)
+	seeds, err := seedURLs(ctx, db, cfg.Preview)
+	if err != nil {
+		return err
+	}
+
+	e := newExporter(handler, *out, *base)
+	err = e.Export(ctx, seeds)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("exported %d pages and %d files to %s\n", e.pages, e.files, *out)
+
+	return nil
+}
+
+// seedURLs returns the URLs the export starts crawling from: the routes and
+// documents nothing else necessarily links to, such as unlisted posts. Every
+// other page is reached by following links.
+//
+// Drafts and scheduled documents are seeded only in preview mode, as the
+// server does not render them otherwise.
+func seedURLs(ctx context.Context, db *bun.DB, preview bool) ([]string, error) {
+	seeds := []string{
+		"/",
+		"/posts",
+		"/projects",
+		"/tags",
+		"/employments",
+		"/500",
+	}
+	var (
+		posts       []*assets.Post
+		projects    []*assets.Project
+		tags        []*assets.Tag
+		employments []*assets.Employment
+		series      []*assets.Series
+		media       []*assets.Media
+		now         = time.Now()
+	)
+	for _, list := range []struct {
+		name    string
+		model   any
+		columns []string
+	}{
+		{"posts", &posts, []string{"slug", "draft", "publish_at"}},
+		{"projects", &projects, []string{"slug", "draft", "publish_at"}},
+		{"tags", &tags, []string{"slug"}},
+		{"employments", &employments, []string{"slug"}},
+		{"series", &series, []string{"slug"}},
+		{"media", &media, []string{"path"}},
+	} {
+		err := db.NewSelect().
+			Model(list.model).
+			Column(list.columns...).
+			Order(list.columns[0]).
+			Scan(ctx)
+		if err != nil {
+			return nil, eris.Wrapf(err, "failed to list %s", list.name)
+		}
+	}
+	for _, p := range posts {
+		if preview || p.IsPublished(now) {
+			seeds = append(seeds, p.PagePath())
+		}
+	}
+	for _, p := range projects {
+		if preview || p.IsPublished(now) {
+			seeds = append(seeds, p.PagePath())
+		}
+	}
+	for _, t := range tags {
+		seeds = append(seeds, t.PagePath())
+	}
+	for _, e := range employments {
+		seeds = append(seeds, e.PagePath())
+	}
+	for _, s := range series {
+		seeds = append(seeds, s.PagePath())
+	}
+	for _, m := range media {
+		seeds = append(seeds, m.PagePath())
+	}
+
+	return seeds, nil
+}
@@ -46,7 +46,7 @@ func run(ctx context.Context) error {
 			panic(err)
 		}
 	}
-	sqlDB, err := sql.Open("sqlite", assets.DBName())
+	sqlDB, err := sql.Open("sqlite", assets.DSN(assets.DBPath(os.Getenv)))
 	if err != nil {
 		panic(err)
 	}
@@ -151,6 +151,7 @@ func run(ctx context.Context) error {
 		&allEmployments,
 	)))
 	comps = append(comps, views.Code500())
+	comps = append(comps, views.Code404())
 	comps = append(comps, components.ThankYou())
 
 	return twerge.CodeGen(
@@ -2,22 +2,28 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
 	"flag"
 	"log/slog"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
 	"syscall"
 
 	"github.com/conneroisu/conneroh.com/internal/assets"
 	"github.com/conneroisu/conneroh.com/internal/copygen"
 	"github.com/conneroisu/conneroh.com/internal/logger"
+	"github.com/conneroisu/conneroh.com/internal/tracing"
 	"github.com/rotisserie/eris"
 	"github.com/spf13/afero"
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect/sqlitedialect"
 	"github.com/uptrace/bun/extra/bundebug"
+	"go.opentelemetry.io/otel/attribute"
 	_ "modernc.org/sqlite"
 )
 
@@ -31,6 +37,12 @@ const (
 	fullEmploymentLoc = assets.EmploymentsLoc
 )
 
+var reconcile = flag.Bool(
+	"reconcile",
+	false,
+	"compare every asset with the bucket, upload only the differences and print a drift report",
+)
+
 func main() {
 	flag.Parse()
 	slog.SetDefault(logger.DefaultLogger)
@@ -53,17 +65,50 @@ func main() {
 func run(
 	ctx context.Context,
 	getenv func(string) string,
-) error {
+) (err error) {
 	var (
-		relFns []assets.RelationshipFn
-		items  []assets.DirMatchItem
+		relFns  []assets.RelationshipFn
+		items   []assets.DirMatchItem
+		skipped []string
 	)
-	sqldb, err := sql.Open("sqlite", assets.DBName())
+	shutdownTracing, err := tracing.Setup(ctx, getenv, "conneroh-update")
+	if err != nil {
+		return eris.Wrap(err, "failed to set up tracing")
+	}
+	defer func() {
+		// Flush even when interrupted, so the spans of the run are kept.
+		flushErr := shutdownTracing(context.WithoutCancel(ctx))
+		if flushErr != nil {
+			slog.Error("failed to flush traces", "err", flushErr)
+		}
+	}()
+	ctx, runSpan := tracing.Start(ctx, "update", attribute.Bool("reconcile", *reconcile))
+	defer func() { tracing.End(runSpan, err) }()
+
+	dbPath, err := stateDB(getenv)
+	if err != nil {
+		return err
+	}
+	if dbPath == "" {
+		var tmp string
+		tmp, err = os.MkdirTemp("", "update")
+		if err != nil {
+			return eris.Wrap(err, "failed to create temporary database directory")
+		}
+		defer os.RemoveAll(tmp)
+		dbPath = filepath.Join(tmp, assets.DefaultDBPath)
+	}
+	err = os.MkdirAll(filepath.Dir(dbPath), 0o755)
+	if err != nil {
+		return eris.Wrap(err, "failed to create database directory")
+	}
+	sqldb, err := sql.Open("sqlite", assets.DSN(dbPath))
 	if err != nil {
 		return eris.Wrap(err, "failed to open database")
 	}
 	defer sqldb.Close()
 	db := bun.NewDB(sqldb, sqlitedialect.New())
+	db.AddQueryHook(tracing.QueryHook{})
 	if os.Getenv("DEBUG") == "true" {
 		db.AddQueryHook(bundebug.NewQueryHook(bundebug.WithVerbose(true)))
 	}
@@ -71,41 +116,124 @@ func run(
 	if err != nil {
 		return eris.Wrap(err, "failed to initialize database")
 	}
+	err = assets.ConfigureAssets(getenv)
+	if err != nil {
+		return eris.Wrap(err, "failed to configure assets")
+	}
 	fs := afero.NewBasePathFs(afero.NewOsFs(), assets.VaultLoc)
-	ti, err := assets.NewTigris(getenv)
+	ti, bucketName, err := assets.OpenStorage(getenv)
 	if err != nil {
-		return eris.Wrap(err, "failed to create Tigris client")
+		return eris.Wrap(err, "failed to open storage")
 	}
-	bucketName := getenv("BUCKET_NAME")
-	if bucketName == "" {
-		return eris.New("BUCKET_NAME environment variable is not set")
+	var mdOpts []assets.MDOption
+	if getenv("RENDER_MODE") == "server" {
+		slog.Info("rendering math and diagrams server-side")
+		mdOpts = append(mdOpts, assets.WithRenderer(assets.NewCLIRenderer()))
 	}
-	md := assets.NewMD(fs)
+	mdOpts = append(mdOpts, assets.WithMedia(func(path string) *assets.Media {
+		var media assets.Media
+		err := db.NewSelect().
+			Model(&media).
+			Where("path = ?", path).
+			Scan(ctx)
+		if err != nil {
+			return nil
+		}
+
+		return &media
+	}))
+	md := assets.NewMD(fs, mdOpts...)
 
 	// Assets
-	items, err = assets.HashDirMatch(ctx, fs, assets.AssetsLoc, db)
+	items, err = assetsToProcess(ctx, fs, db)
 	if err != nil {
 		return err
 	}
+	var (
+		uploads []assets.Upload
+		changed []string
+	)
 	for _, item := range items {
-		slog.Info("uploading to S3", "path", item.Path)
-		err = assets.UploadToS3(
+		changed = append(changed, assets.Pathify(item.Path))
+		data, err := assets.ProcessAsset(
 			ctx,
-			ti,
-			bucketName,
-			item.Path,
+			db,
+			assets.Pathify(item.Path),
 			[]byte(item.Content),
 		)
 		if err != nil {
-			return eris.Wrap(err, "failed to upload to S3")
+			return eris.Wrap(err, "failed to process asset")
+		}
+		uploads = append(uploads, assets.Upload{Path: item.Path, Data: data})
+		if assets.IsVariantSource(item.Path) {
+			var variants []assets.Upload
+			variants, err = makeVariants(ctx, db, item.Path, data)
+			if err != nil {
+				return err
+			}
+			uploads = append(uploads, variants...)
+		}
+		if assets.IsVideo(item.Path) {
+			var (
+				poster []assets.Upload
+				reason string
+			)
+			poster, reason, err = makePoster(ctx, db, item.Path, data)
+			if err != nil {
+				return err
+			}
+			if reason != "" {
+				skipped = append(skipped, reason)
+			}
+			uploads = append(uploads, poster...)
 		}
 	}
+	uploads, entries := assets.ContentAddress(uploads)
+	uploader := assets.NewUploader(ti, bucketName)
+	uploader.Concurrency = numWorkers
+	uploader.SkipUnchanged = true
+	uploader.CacheControl = assets.ImmutableCacheControl
+	if *reconcile {
+		var (
+			remote map[string]string
+			drift  assets.Drift
+		)
+		remote, err = assets.RemoteETags(ctx, ti, bucketName, assets.AssetsLoc)
+		if err != nil {
+			return err
+		}
+		uploads, drift = uploader.Reconcile(uploads, remote)
+		drift.Report(os.Stdout)
+		uploader.SkipUnchanged = false
+	}
+	uctx, span := tracing.Start(ctx, "upload", attribute.Int("uploads", len(uploads)))
+	err = uploader.UploadAll(uctx, uploads)
+	tracing.End(span, err)
+	if err != nil {
+		return eris.Wrap(err, "failed to upload to S3")
+	}
+	err = assets.UpsertManifest(ctx, db, entries)
+	if err != nil {
+		return err
+	}
+	err = assets.LoadMedia(ctx, db)
+	if err != nil {
+		return eris.Wrap(err, "failed to load media")
+	}
+	users, err := assets.DocsUsing(ctx, db, changed)
+	if err != nil {
+		return err
+	}
 
 	// Posts
-	items, err = assets.HashDirMatch(ctx, fs, assets.PostsLoc, db)
+	items, err = hashDir(ctx, fs, assets.PostsLoc, db)
 	if err != nil {
 		return eris.Wrap(err, "failed to hash posts")
 	}
+	items, err = withUsers(ctx, fs, items, users, assets.PostsLoc)
+	if err != nil {
+		return err
+	}
 	for _, item := range items {
 		slog.Info("processing post", "path", item.Path)
 		var (
@@ -113,12 +241,29 @@ func run(
 			doc   *assets.Doc
 			relFn assets.RelationshipFn
 		)
+		_, span := tracing.Start(ctx, "parse", attribute.String("path", item.Path))
 		doc, err = assets.ParseMarkdown(md, item)
+		tracing.End(span, err)
 		if err != nil {
 			return eris.Wrap(err, "failed to parse markdown")
 		}
+		err = recordHistory(ctx, fs, db, item.Path)
+		if err != nil {
+			return err
+		}
 		copygen.ToPost(&post, doc)
-		relFn, err = assets.UpsertPost(ctx, db, &post)
+		if doc.SeriesSlug != "" {
+			err = assets.UpsertSeries(ctx, db, &assets.Series{
+				Title: doc.SeriesTitle,
+				Slug:  doc.SeriesSlug,
+			})
+			if err != nil {
+				return eris.Wrap(err, "failed to upsert series")
+			}
+		}
+		uctx, span := tracing.Start(ctx, "upsert", attribute.String("path", item.Path))
+		relFn, err = assets.UpsertPost(uctx, db, &post)
+		tracing.End(span, err)
 		if err != nil {
 			return eris.Wrap(err, "failed to upsert post")
 		}
@@ -126,10 +271,14 @@ func run(
 	}
 
 	// Projects
-	items, err = assets.HashDirMatch(ctx, fs, assets.ProjectsLoc, db)
+	items, err = hashDir(ctx, fs, assets.ProjectsLoc, db)
 	if err != nil {
 		return eris.Wrap(err, "failed to hash projects")
 	}
+	items, err = withUsers(ctx, fs, items, users, assets.ProjectsLoc)
+	if err != nil {
+		return err
+	}
 	for _, item := range items {
 		slog.Info("processing project", "path", item.Path)
 		var (
@@ -137,12 +286,20 @@ func run(
 			doc     *assets.Doc
 			relFn   assets.RelationshipFn
 		)
+		_, span := tracing.Start(ctx, "parse", attribute.String("path", item.Path))
 		doc, err = assets.ParseMarkdown(md, item)
+		tracing.End(span, err)
 		if err != nil {
 			return eris.Wrap(err, "failed to parse markdown")
 		}
+		err = recordHistory(ctx, fs, db, item.Path)
+		if err != nil {
+			return err
+		}
 		copygen.ToProject(&project, doc)
-		relFn, err = assets.UpsertProject(ctx, db, &project)
+		uctx, span := tracing.Start(ctx, "upsert", attribute.String("path", item.Path))
+		relFn, err = assets.UpsertProject(uctx, db, &project)
+		tracing.End(span, err)
 		if err != nil {
 			return eris.Wrap(err, "failed to upsert project")
 		}
@@ -150,7 +307,7 @@ func run(
 	}
 
 	// Tags
-	items, err = assets.HashDirMatch(ctx, fs, assets.TagsLoc, db)
+	items, err = hashDir(ctx, fs, assets.TagsLoc, db)
 	if err != nil {
 		return eris.Wrap(err, "failed to hash tags")
 	}
@@ -161,19 +318,27 @@ func run(
 			doc   *assets.Doc
 			relFn assets.RelationshipFn
 		)
+		_, span := tracing.Start(ctx, "parse", attribute.String("path", item.Path))
 		doc, err = assets.ParseMarkdown(md, item)
+		tracing.End(span, err)
 		if err != nil {
 			return eris.Wrap(err, "failed to parse markdown")
 		}
+		err = recordHistory(ctx, fs, db, item.Path)
+		if err != nil {
+			return err
+		}
 		copygen.ToTag(&tag, doc)
-		relFn, err = assets.UpsertTag(ctx, db, &tag)
+		uctx, span := tracing.Start(ctx, "upsert", attribute.String("path", item.Path))
+		relFn, err = assets.UpsertTag(uctx, db, &tag)
+		tracing.End(span, err)
 		if err != nil {
 			return eris.Wrap(err, "failed to upsert tag")
 		}
 		relFns = append(relFns, relFn)
 	}
 
-	todoEmployments, err := assets.HashDirMatch(ctx, fs, fullEmploymentLoc, db)
+	todoEmployments, err := hashDir(ctx, fs, fullEmploymentLoc, db)
 	if err != nil {
 		return eris.Wrap(err, "failed to hash employments")
 	}
@@ -184,12 +349,20 @@ func run(
 			doc        *assets.Doc
 			relFn      assets.RelationshipFn
 		)
+		_, span := tracing.Start(ctx, "parse", attribute.String("path", item.Path))
 		doc, err = assets.ParseMarkdown(md, item)
+		tracing.End(span, err)
 		if err != nil {
 			return eris.Wrap(err, "failed to parse markdown")
 		}
+		err = recordHistory(ctx, fs, db, item.Path)
+		if err != nil {
+			return err
+		}
 		copygen.ToEmployment(&employment, doc)
-		relFn, err = assets.UpsertEmployment(ctx, db, &employment)
+		uctx, span := tracing.Start(ctx, "upsert", attribute.String("path", item.Path))
+		relFn, err = assets.UpsertEmployment(uctx, db, &employment)
+		tracing.End(span, err)
 		if err != nil {
 			return eris.Wrap(err, "failed to upsert employment")
 		}
@@ -197,8 +370,60 @@ func run(
 	}
 
 	slog.Info("upserting relationships")
+	err = upsertRelationships(ctx, relFns)
+	if err != nil {
+		return err
+	}
+
+	for _, reason := range skipped {
+		slog.Warn("skipped", "reason", reason)
+	}
+
+	return nil
+}
+
+// stateDB returns the database the run records its progress in, "" for a
+// temporary one. Runs against local storage use their own, so the site's
+// database keeps tracking what was published to the bucket.
+func stateDB(getenv func(string) string) (string, error) {
+	site := assets.DBPath(getenv)
+	path, err := assets.StateDBPath(getenv, site)
+	if err != nil {
+		return "", eris.Wrap(err, "failed to open storage")
+	}
+	if path != site {
+		slog.Info("keeping the state of local storage out of the site database",
+			"site", site, "state", cmp.Or(path, "temporary"))
+	}
+
+	return path, nil
+}
+
+// hashDir returns the documents under loc whose hash changed since the last
+// run.
+func hashDir(
+	ctx context.Context,
+	fs afero.Fs,
+	loc string,
+	db *bun.DB,
+) ([]assets.DirMatchItem, error) {
+	ctx, span := tracing.Start(ctx, "hash", attribute.String("loc", loc))
+	items, err := assets.HashDirMatch(ctx, fs, loc, db)
+	tracing.End(span, err)
+
+	return items, err
+}
+
+// upsertRelationships links the upserted documents to the documents, tags
+// and media they reference.
+func upsertRelationships(
+	ctx context.Context,
+	relFns []assets.RelationshipFn,
+) (err error) {
+	ctx, span := tracing.Start(ctx, "relationships", attribute.Int("documents", len(relFns)))
+	defer func() { tracing.End(span, err) }()
 	for _, fn := range relFns {
-		err := fn(ctx)
+		err = fn(ctx)
 		if err != nil {
 			return eris.Wrap(err, "failed to run relationship function")
 		}
@@ -206,3 +431,149 @@ func run(
 
 	return nil
 }
+
+// assetsToProcess returns the assets that changed since the last run along
+// with those that have no media row yet, or every asset when reconciling
+// with the bucket.
+func assetsToProcess(
+	ctx context.Context,
+	fs afero.Fs,
+	db *bun.DB,
+) ([]assets.DirMatchItem, error) {
+	if *reconcile {
+		return assets.MatchDir(fs, assets.AssetsLoc)
+	}
+	items, err := hashDir(ctx, fs, assets.AssetsLoc, db)
+	if err != nil {
+		return nil, err
+	}
+	missing, err := assets.MissingMedia(ctx, fs, db)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range missing {
+		if !slices.ContainsFunc(items, func(i assets.DirMatchItem) bool {
+			return i.Path == item.Path
+		}) {
+			items = append(items, item)
+		}
+	}
+
+	return items, nil
+}
+
+// withUsers adds the documents under loc that use changed assets to items,
+// as their rendered content links to the assets' old content-addressed keys.
+func withUsers(
+	ctx context.Context,
+	fs afero.Fs,
+	items []assets.DirMatchItem,
+	users []string,
+	loc string,
+) ([]assets.DirMatchItem, error) {
+	for _, path := range users {
+		if !strings.HasPrefix(path, loc) ||
+			slices.ContainsFunc(items, func(i assets.DirMatchItem) bool {
+				return i.Path == path
+			}) {
+			continue
+		}
+		item, err := assets.MatchItem(fs, path)
+		if err != nil {
+			return nil, eris.Wrapf(err, "failed to read document: %s", path)
+		}
+		item.ModTime, err = assets.LastModified(ctx, fs, path)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// recordHistory stores the git history of the document at path.
+func recordHistory(
+	ctx context.Context,
+	fs afero.Fs,
+	db *bun.DB,
+	path string,
+) error {
+	revisions, err := assets.History(ctx, fs, path)
+	if err != nil {
+		return eris.Wrapf(err, "failed to read history of %s", path)
+	}
+
+	return assets.UpsertRevisions(ctx, db, path, revisions)
+}
+
+// makeVariants generates and records the resized and re-encoded variants of
+// an image asset and returns the ones to upload.
+func makeVariants(
+	ctx context.Context,
+	db *bun.DB,
+	assetPath string,
+	data []byte,
+) ([]assets.Upload, error) {
+	path := assets.Pathify(assetPath)
+	variants, err := assets.MakeVariants(
+		ctx,
+		assets.CLIImageEncoder{},
+		path,
+		data,
+	)
+	if err != nil {
+		return nil, eris.Wrapf(err, "failed to make variants of %s", path)
+	}
+	var (
+		records = make([]*assets.ImageVariant, 0, len(variants))
+		uploads []assets.Upload
+	)
+	for _, variant := range variants {
+		records = append(records, &variant.ImageVariant)
+		if variant.Data == nil {
+			continue
+		}
+		uploads = append(uploads, assets.Upload{
+			Path: assets.AssetsLoc + variant.Key,
+			Data: variant.Data,
+		})
+	}
+
+	return uploads, assets.UpsertImageVariants(ctx, db, path, records)
+}
+
+// makePoster extracts and records the poster frame of a video asset and
+// returns it to upload.
+//
+// When no poster can be extracted, the video is kept without one and the
+// reason is returned for the end of run report.
+func makePoster(
+	ctx context.Context,
+	db *bun.DB,
+	assetPath string,
+	data []byte,
+) ([]assets.Upload, string, error) {
+	path := assets.Pathify(assetPath)
+	media, poster, err := assets.DescribeVideo(
+		ctx,
+		assets.FFmpegExtractor{},
+		path,
+		data,
+	)
+	if eris.Is(err, assets.ErrUnsupportedFormat) {
+		return nil, "poster of " + path + ": " + err.Error(), nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	err = assets.UpsertMedia(ctx, db, media)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return []assets.Upload{{
+		Path: assets.AssetsLoc + media.Poster,
+		Data: poster,
+	}}, "", nil
+}
@@ -131,6 +131,7 @@
           run = {
             text = rooted ''cd "$REPO_ROOT" && air'';
             env.DEBUG = "true";
+            env.LOG_LEVEL = "debug";
             runtimeInputs = with pkgs; [air git];
             description = "Run the application with air for hot reloading";
           };
@@ -307,6 +308,15 @@
               auto_stop_machines = "stop";
               auto_start_machines = true;
               min_machines_running = 0;
+              checks = [
+                {
+                  grace_period = "10s";
+                  interval = "15s";
+                  method = "GET";
+                  path = "/readyz";
+                  timeout = "2s";
+                }
+              ];
             };
             vm = [
               {
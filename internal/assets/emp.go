@@ -2,7 +2,10 @@ package assets
 
 import (
 	"context"
+	"reflect"
+	"slices"
 
+	"github.com/rotisserie/eris"
 	"github.com/uptrace/bun"
 )
 
@@ -17,12 +20,26 @@ var (
 	EmpEmployment = new(Employment)
 	// EmpCache is a pointer to a Cache.
 	EmpCache = new(Cache)
+	// EmpRevision is a pointer to a Revision.
+	EmpRevision = new(Revision)
+	// EmpSeries is a pointer to a Series.
+	EmpSeries = new(Series)
+	// EmpImageVariant is a pointer to an ImageVariant.
+	EmpImageVariant = new(ImageVariant)
+	// EmpMedia is a pointer to a Media.
+	EmpMedia = new(Media)
+	// EmpManifestEntry is a pointer to a ManifestEntry.
+	EmpManifestEntry = new(ManifestEntry)
 	// EmpPostToTag is a pointer to a PostToTag.
 	EmpPostToTag = new(PostToTag)
 	// EmpPostToPost is a pointer to a PostToPost.
 	EmpPostToPost = new(PostToPost)
 	// EmpPostToProject is a pointer to a PostToProject.
 	EmpPostToProject = new(PostToProject)
+	// EmpMediaToPost is a pointer to a MediaToPost.
+	EmpMediaToPost = new(MediaToPost)
+	// EmpMediaToProject is a pointer to a MediaToProject.
+	EmpMediaToProject = new(MediaToProject)
 	// EmpProjectToTag is a pointer to a ProjectToTag.
 	EmpProjectToTag = new(ProjectToTag)
 	// EmpProjectToProject is a pointer to a ProjectToProject.
@@ -43,6 +60,8 @@ var models = []any{
 	EmpPostToTag,
 	EmpPostToPost,
 	EmpPostToProject,
+	EmpMediaToPost,
+	EmpMediaToProject,
 	EmpProjectToTag,
 	EmpProjectToProject,
 	EmpTagToTag,
@@ -55,6 +74,11 @@ var models = []any{
 	EmpProject,
 	EmpEmployment,
 	EmpCache,
+	EmpRevision,
+	EmpSeries,
+	EmpImageVariant,
+	EmpMedia,
+	EmpManifestEntry,
 }
 
 // InitDB initializes the database.
@@ -62,6 +86,10 @@ func InitDB(
 	ctx context.Context,
 	db *bun.DB,
 ) error {
+	err := rebuildUniqueCacheHash(ctx, db)
+	if err != nil {
+		return err
+	}
 	for _, model := range models {
 		_, err := db.NewCreateTable().
 			Model(model).
@@ -70,6 +98,86 @@ func InitDB(
 		if err != nil {
 			return err
 		}
+		err = addMissingColumns(ctx, db, model)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rebuildUniqueCacheHash rebuilds the caches table of databases created when
+// cache hashes had to be unique, which failed on files sharing their content.
+// SQLite cannot drop the constraint in place, so the rows are copied into a
+// new table without it.
+func rebuildUniqueCacheHash(ctx context.Context, db *bun.DB) error {
+	var count int
+	err := db.NewRaw(
+		`SELECT count(*) FROM pragma_index_list('caches') AS l
+		JOIN pragma_index_info(l.name) AS i
+		WHERE l."unique" AND i.name = 'hashed'`,
+	).Scan(ctx, &count)
+	if err != nil || count == 0 {
+		return err
+	}
+
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.ExecContext(ctx, "ALTER TABLE caches RENAME TO caches_unique_hash")
+		if err != nil {
+			return eris.Wrap(err, "failed to rename caches")
+		}
+		_, err = tx.NewCreateTable().
+			Model((*Cache)(nil)).
+			Exec(ctx)
+		if err != nil {
+			return eris.Wrap(err, "failed to create caches")
+		}
+		_, err = tx.ExecContext(
+			ctx,
+			`INSERT INTO caches (id, path, hashed)
+			SELECT id, path, hashed FROM caches_unique_hash`,
+		)
+		if err != nil {
+			return eris.Wrap(err, "failed to copy caches")
+		}
+		_, err = tx.ExecContext(ctx, "DROP TABLE caches_unique_hash")
+		if err != nil {
+			return eris.Wrap(err, "failed to drop old caches")
+		}
+
+		return nil
+	})
+}
+
+// addMissingColumns adds the columns of a model that are missing from its
+// already existing table, so databases created before a field was added keep
+// working.
+func addMissingColumns(
+	ctx context.Context,
+	db *bun.DB,
+	model any,
+) error {
+	var existing []string
+	table := db.Table(reflect.TypeOf(model))
+	err := db.NewRaw(
+		"SELECT name FROM pragma_table_info(?)",
+		table.Name,
+	).Scan(ctx, &existing)
+	if err != nil {
+		return err
+	}
+	for _, field := range table.Fields {
+		if slices.Contains(existing, field.Name) {
+			continue
+		}
+		_, err = db.NewAddColumn().
+			Model(model).
+			ColumnExpr("? ?", field.SQLName, bun.Safe(field.CreateTableSQLType)).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -82,6 +190,8 @@ func RegisterModels(db *bun.DB) {
 		(*PostToTag)(nil),
 		(*PostToPost)(nil),
 		(*PostToProject)(nil),
+		(*MediaToPost)(nil),
+		(*MediaToProject)(nil),
 		(*ProjectToTag)(nil),
 		(*ProjectToProject)(nil),
 		(*TagToTag)(nil),
@@ -94,5 +204,10 @@ func RegisterModels(db *bun.DB) {
 		(*Project)(nil),
 		(*Employment)(nil),
 		(*Cache)(nil),
+		(*Revision)(nil),
+		(*Series)(nil),
+		(*ImageVariant)(nil),
+		(*Media)(nil),
+		(*ManifestEntry)(nil),
 	)
 }
@@ -0,0 +1,54 @@
+package assets_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/conneroisu/conneroh.com/internal/assets"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	_ "modernc.org/sqlite"
+)
+
+func TestInitDBKeepsCaches(t *testing.T) {
+	ctx := context.Background()
+	sqldb, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqldb.SetMaxOpenConns(1)
+	db := bun.NewDB(sqldb, sqlitedialect.New())
+	defer db.Close()
+
+	// The caches table as created when hashes had to be unique.
+	_, err = db.ExecContext(ctx, `CREATE TABLE caches (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		path VARCHAR UNIQUE,
+		hashed VARCHAR UNIQUE
+	)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.ExecContext(ctx, `INSERT INTO caches (path, hashed) VALUES ('a.md', 'h1'), ('b.md', 'h2')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		if err := assets.InitDB(ctx, db); err != nil {
+			t.Fatalf("InitDB() error = %v", err)
+		}
+	}
+	var caches []assets.Cache
+	if err := db.NewSelect().Model(&caches).Order("path").Scan(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(caches) != 2 || caches[0].Path != "a.md" || caches[1].Hash != "h2" {
+		t.Errorf("InitDB() kept caches %+v, want a.md and b.md", caches)
+	}
+	_, err = db.NewInsert().Model(&assets.Cache{Path: "c.md", Hash: "h1"}).Exec(ctx)
+	if err != nil {
+		t.Errorf("Insert() of a shared hash error = %v, want nil", err)
+	}
+}
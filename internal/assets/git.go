@@ -0,0 +1,170 @@
+package assets
+
+import (
+	"context"
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rotisserie/eris"
+	"github.com/spf13/afero"
+)
+
+// historyUnavailable logs once that documents have no history, as it is the
+// same for every document.
+var historyUnavailable sync.Once
+
+// realPather is implemented by filesystems that map to a directory on disk
+// such as afero.BasePathFs.
+type realPather interface {
+	RealPath(name string) (string, error)
+}
+
+// LastModified returns when the file at path last changed.
+//
+// It prefers the commit time of the last git commit touching the file and
+// falls back to the file's modification time when the file is untracked or
+// git is unavailable.
+func LastModified(ctx context.Context, fs afero.Fs, path string) (time.Time, error) {
+	if rp, ok := fs.(realPather); ok {
+		realPath, err := rp.RealPath(path)
+		if err == nil {
+			t, err := gitCommitTime(ctx, realPath)
+			if err == nil && !t.IsZero() {
+				return t, nil
+			}
+		}
+	}
+	info, err := fs.Stat(path)
+	if err != nil {
+		return time.Time{}, eris.Wrapf(err, "failed to stat %s", path)
+	}
+
+	return info.ModTime(), nil
+}
+
+// gitCommitTime returns the commit time of the last commit touching the file
+// at realPath or the zero time if the file has never been committed.
+func gitCommitTime(ctx context.Context, realPath string) (time.Time, error) {
+	out, err := exec.CommandContext(
+		ctx,
+		"git",
+		"-C", filepath.Dir(realPath),
+		"log", "-1", "--format=%cI",
+		"--", filepath.Base(realPath),
+	).Output()
+	if err != nil {
+		return time.Time{}, eris.Wrapf(err, "failed to read git log for %s", realPath)
+	}
+	stamp := strings.TrimSpace(string(out))
+	if stamp == "" {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(time.RFC3339, stamp)
+}
+
+// History returns the revisions of the file at path, newest first.
+//
+// Each revision carries the file's source as of that commit. Filesystems that
+// do not map to a directory on disk have no history, nor do files outside of
+// a git work tree, such as an exported tree, or when git is not installed.
+func History(ctx context.Context, fs afero.Fs, path string) ([]*Revision, error) {
+	rp, ok := fs.(realPather)
+	if !ok {
+		return nil, nil
+	}
+	realPath, err := rp.RealPath(path)
+	if err != nil {
+		return nil, eris.Wrapf(err, "failed to resolve %s", path)
+	}
+	dir, base := filepath.Dir(realPath), filepath.Base(realPath)
+	if !inWorkTree(ctx, dir) {
+		if ctx.Err() != nil {
+			return nil, eris.Wrapf(ctx.Err(), "failed to read git history for %s", path)
+		}
+		historyUnavailable.Do(func() {
+			slog.Warn(
+				"git history is unavailable, documents have no revisions",
+				slog.String("dir", dir),
+			)
+		})
+
+		return nil, nil
+	}
+	// The names --name-status reports are relative to the top of the
+	// repository, which is where git show resolves them from too.
+	out, err := exec.CommandContext(
+		ctx,
+		"git",
+		"-C", dir,
+		"log", "--follow", "--name-status", "--format=%x1e%H%x1f%cI%x1f%s",
+		"--", base,
+	).Output()
+	if err != nil {
+		return nil, eris.Wrapf(err, "failed to read git history for %s", path)
+	}
+
+	var revisions []*Revision
+	for _, record := range strings.Split(string(out), "\x1e") {
+		header, changes, _ := strings.Cut(strings.TrimSpace(record), "\n")
+		fields := strings.SplitN(header, "\x1f", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		date, err := time.Parse(time.RFC3339, fields[1])
+		if err != nil {
+			return nil, eris.Wrapf(err, "failed to parse commit date %q", fields[1])
+		}
+		// A change is the status followed by the file's names, the last being
+		// its name as of the commit, e.g. "R100\told.md\tnew.md".
+		change := strings.Split(strings.TrimSpace(changes), "\t")
+		var source []byte
+		if len(change) > 1 && !strings.HasPrefix(change[0], "D") {
+			name := change[len(change)-1]
+			source, err = exec.CommandContext(
+				ctx,
+				"git",
+				"-C", dir,
+				"show", fields[0]+":"+name,
+			).Output()
+			if err != nil {
+				return nil, eris.Wrapf(err, "failed to read %s at %s", name, fields[0])
+			}
+		}
+		revisions = append(revisions, &Revision{
+			DocPath: path,
+			Hash:    fields[0],
+			Date:    date,
+			Summary: fields[2],
+			Source:  string(source),
+		})
+	}
+
+	return revisions, nil
+}
+
+// ShortHash returns the abbreviated commit hash of the revision.
+func (r *Revision) ShortHash() string {
+	if len(r.Hash) > 7 {
+		return r.Hash[:7]
+	}
+
+	return r.Hash
+}
+
+// inWorkTree reports whether dir is inside a git work tree, false if git is
+// not installed.
+func inWorkTree(ctx context.Context, dir string) bool {
+	out, err := exec.CommandContext(
+		ctx,
+		"git",
+		"-C", dir,
+		"rev-parse", "--is-inside-work-tree",
+	).Output()
+
+	return err == nil && strings.TrimSpace(string(out)) == "true"
+}
@@ -0,0 +1,107 @@
+package assets_test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/conneroisu/conneroh.com/internal/assets"
+	"github.com/spf13/afero"
+)
+
+func TestHistory(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	ctx := context.Background()
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(
+			os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+		)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v error = %v: %s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git("init", "-q")
+	write("posts/old.md", "# First draft\n")
+	git("add", "-A")
+	git("commit", "-q", "-m", "add post")
+	git("mv", "posts/old.md", "posts/new.md")
+	git("commit", "-q", "-m", "rename post")
+	write("posts/new.md", "# Final draft\n")
+	git("commit", "-q", "-a", "-m", "edit post")
+
+	fs := afero.NewBasePathFs(afero.NewOsFs(), filepath.Join(dir, "posts"))
+	revisions, err := assets.History(ctx, fs, "new.md")
+	if err != nil {
+		t.Fatalf("History() error = %v", err)
+	}
+	want := []struct {
+		summary, source string
+	}{
+		{"edit post", "# Final draft\n"},
+		{"rename post", "# First draft\n"},
+		{"add post", "# First draft\n"},
+	}
+	if len(revisions) != len(want) {
+		t.Fatalf("History() = %d revisions, want %d", len(revisions), len(want))
+	}
+	for i, w := range want {
+		if revisions[i].Summary != w.summary {
+			t.Errorf("History()[%d].Summary = %q, want %q", i, revisions[i].Summary, w.summary)
+		}
+		if revisions[i].Source != w.source {
+			t.Errorf("History()[%d].Source = %q, want %q", i, revisions[i].Source, w.source)
+		}
+	}
+
+	revisions, err = assets.History(ctx, afero.NewMemMapFs(), "new.md")
+	if err != nil || revisions != nil {
+		t.Errorf("History() on memory = %v, %v, want nil, nil", revisions, err)
+	}
+}
+
+func TestHistoryUnavailable(t *testing.T) {
+	tests := []struct {
+		name string
+		// noGit hides git from the search path.
+		noGit bool
+	}{
+		{name: "Not a work tree"},
+		{name: "Git not installed", noGit: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "post.md"), []byte("# Post\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.noGit {
+				t.Setenv("PATH", t.TempDir())
+			}
+			fs := afero.NewBasePathFs(afero.NewOsFs(), dir)
+			revisions, err := assets.History(context.Background(), fs, "post.md")
+			if err != nil || revisions != nil {
+				t.Errorf("History() = %v, %v, want nil, nil", revisions, err)
+			}
+		})
+	}
+}
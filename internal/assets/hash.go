@@ -10,6 +10,7 @@ import (
 	"os"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/rotisserie/eris"
 	"github.com/spf13/afero"
@@ -44,20 +45,13 @@ type DirMatchItem struct {
 	Path    string
 	Content string
 	Hash    string
+	// ModTime is when the file last changed, set for changed files only.
+	ModTime time.Time
 }
 
-// HashDirMatch takes an fs, path, and a db.
-//
-// It returns a slice of paths if the hash of the directory does not match the
-// hash in the database.
-func HashDirMatch(
-	ctx context.Context,
-	fs afero.Fs,
-	path string,
-	db *bun.DB,
-) ([]DirMatchItem, error) {
+// MatchDir returns every file under path, sorted by path.
+func MatchDir(fs afero.Fs, path string) ([]DirMatchItem, error) {
 	var files []string
-	var pathMap []DirMatchItem
 	err := afero.Walk(
 		fs,
 		path,
@@ -79,17 +73,37 @@ func HashDirMatch(
 	// Sort files for consistent ordering
 	sort.Strings(files)
 
-	// Create a concatenated string of paths and hashes, then hash that
-	var hashInputBuilder strings.Builder
-
+	items := make([]DirMatchItem, 0, len(files))
 	for _, file := range files {
-		var matchItem DirMatchItem
-		matchItem, err = MatchItem(fs, file)
+		item, err := MatchItem(fs, file)
 		if err != nil {
 			return nil, err
 		}
-		pathMap = append(pathMap, matchItem)
+		items = append(items, item)
+	}
 
+	return items, nil
+}
+
+// HashDirMatch takes an fs, path, and a db.
+//
+// It returns a slice of paths if the hash of the directory does not match the
+// hash in the database.
+func HashDirMatch(
+	ctx context.Context,
+	fs afero.Fs,
+	path string,
+	db *bun.DB,
+) ([]DirMatchItem, error) {
+	pathMap, err := MatchDir(fs, path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a concatenated string of paths and hashes, then hash that
+	var hashInputBuilder strings.Builder
+
+	for _, matchItem := range pathMap {
 		_, err = hashInputBuilder.WriteString(matchItem.Hash)
 		if err != nil {
 			return nil, err
@@ -121,6 +135,10 @@ func HashDirMatch(
 			return nil, eris.Wrap(err, "failed to check cache")
 		}
 		if dirCache.Hash != value.Hash {
+			value.ModTime, err = LastModified(ctx, fs, value.Path)
+			if err != nil {
+				return nil, err
+			}
 			changedFiles = append(changedFiles, value)
 		}
 	}
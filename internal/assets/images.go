@@ -0,0 +1,268 @@
+package assets
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rotisserie/eris"
+	"golang.org/x/image/draw"
+
+	// Register decoders for the raster formats that get variants.
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// VariantWidths are the widths, in pixels, of the resized variants generated
+// for raster images.
+var VariantWidths = []int{480, 960, 1920}
+
+// variantTypes are the modern formats every variant is also encoded as.
+var variantTypes = []string{"image/avif", "image/webp"}
+
+// ErrUnsupportedFormat is returned by an ImageEncoder that cannot produce the
+// requested format.
+var ErrUnsupportedFormat = eris.New("unsupported image format")
+
+// ImageEncoder encodes images into formats the standard library cannot write.
+type ImageEncoder interface {
+	Encode(ctx context.Context, img image.Image, contentType string) ([]byte, error)
+}
+
+// CLIImageEncoder is an ImageEncoder backed by the cwebp and avifenc
+// executables.
+type CLIImageEncoder struct{}
+
+// Encode encodes img as WebP with cwebp or as AVIF with avifenc.
+func (CLIImageEncoder) Encode(
+	ctx context.Context,
+	img image.Image,
+	contentType string,
+) ([]byte, error) {
+	var (
+		name string
+		args []string
+	)
+	switch contentType {
+	case "image/webp":
+		name, args = "cwebp", []string{"-quiet", "-q", "80", "{in}", "-o", "{out}"}
+	case "image/avif":
+		name, args = "avifenc", []string{"--jobs", "all", "{in}", "{out}"}
+	default:
+		return nil, eris.Wrap(ErrUnsupportedFormat, contentType)
+	}
+	if err := lookPath(name, contentType+" variants"); err != nil {
+		return nil, eris.Wrap(err, contentType)
+	}
+
+	dir, err := os.MkdirTemp("", "variant")
+	if err != nil {
+		return nil, eris.Wrap(err, "failed to create temporary directory")
+	}
+	defer os.RemoveAll(dir)
+	in, out := filepath.Join(dir, "in.png"), filepath.Join(dir, "out")
+	var buf bytes.Buffer
+	err = png.Encode(&buf, img)
+	if err != nil {
+		return nil, eris.Wrap(err, "failed to encode png")
+	}
+	err = os.WriteFile(in, buf.Bytes(), 0o600)
+	if err != nil {
+		return nil, eris.Wrap(err, "failed to write image")
+	}
+	for i, arg := range args {
+		args[i] = strings.NewReplacer("{in}", in, "{out}", out).Replace(arg)
+	}
+	output, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return nil, eris.Wrapf(err, "%s failed: %s", name, output)
+	}
+
+	return os.ReadFile(out)
+}
+
+// missingTools holds the executables found missing, so that each is warned
+// about once per run rather than once per asset.
+var missingTools sync.Map
+
+// lookPath returns ErrUnsupportedFormat if the executable name, which the
+// assets need for what, is not installed, warning about it the first time.
+func lookPath(name, what string) error {
+	if _, err := exec.LookPath(name); err != nil {
+		if _, warned := missingTools.LoadOrStore(name, true); !warned {
+			slog.Warn("executable not found, skipping "+what, "executable", name)
+		}
+
+		return eris.Wrapf(ErrUnsupportedFormat, "%s not found", name)
+	}
+
+	return nil
+}
+
+// Variant is an encoded variant of an image.
+type Variant struct {
+	ImageVariant
+
+	Data []byte
+}
+
+// IsVariantSource returns true if variants are generated for the asset at
+// path.
+//
+// Vector, animated and already modern images are served as is.
+func IsVariantSource(path string) bool {
+	switch GetContentType(path) {
+	case "image/png", "image/jpeg", "image/webp", "image/tiff":
+		return true
+	default:
+		return false
+	}
+}
+
+// VariantKey returns the asset path of the variant of the image at path with
+// the given width and content type, e.g. "banner.png" becomes
+// "banner.png.480w.webp". The original's extension is kept so that images
+// differing only in format, such as "banner.png" and "banner.jpg", do not
+// share variants.
+func VariantKey(path string, width int, contentType string) string {
+	return path + "." + strconv.Itoa(width) + "w" + variantExt(contentType)
+}
+
+// MakeVariants decodes the image at path and encodes it resized to every
+// width in VariantWidths narrower than the original, as well as at its
+// original width, in its own format and the formats the encoder supports.
+//
+// The original itself is included as the first variant without data.
+func MakeVariants(
+	ctx context.Context,
+	enc ImageEncoder,
+	path string,
+	data []byte,
+) ([]*Variant, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, eris.Wrapf(err, "failed to decode image: %s", path)
+	}
+	bounds := src.Bounds()
+	variants := []*Variant{{ImageVariant: ImageVariant{
+		Path:        path,
+		Key:         path,
+		ContentType: GetContentType(path),
+		Width:       bounds.Dx(),
+		Height:      bounds.Dy(),
+	}}}
+	baseType := FallbackType(path)
+	for _, width := range append(narrower(bounds.Dx()), bounds.Dx()) {
+		img := resize(src, width)
+		for _, contentType := range append([]string{baseType}, variantTypes...) {
+			if width == bounds.Dx() && contentType == variants[0].ContentType {
+				continue
+			}
+			var out []byte
+			out, err = encode(ctx, enc, img, contentType)
+			if eris.Is(err, ErrUnsupportedFormat) {
+				continue
+			}
+			if err != nil {
+				return nil, eris.Wrapf(err, "failed to encode %s", path)
+			}
+			variants = append(variants, &Variant{
+				ImageVariant: ImageVariant{
+					Path:        path,
+					Key:         VariantKey(path, width, contentType),
+					ContentType: contentType,
+					Width:       img.Bounds().Dx(),
+					Height:      img.Bounds().Dy(),
+				},
+				Data: out,
+			})
+		}
+	}
+
+	return variants, nil
+}
+
+// narrower returns the variant widths narrower than width.
+func narrower(width int) []int {
+	var widths []int
+	for _, w := range VariantWidths {
+		if w < width {
+			widths = append(widths, w)
+		}
+	}
+
+	return widths
+}
+
+// resize scales img to the given width keeping its aspect ratio.
+func resize(img image.Image, width int) image.Image {
+	bounds := img.Bounds()
+	if bounds.Dx() == width {
+		return img
+	}
+	height := max(1, bounds.Dy()*width/bounds.Dx())
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+
+	return dst
+}
+
+// encode encodes img as contentType, using the standard library where it can.
+func encode(
+	ctx context.Context,
+	enc ImageEncoder,
+	img image.Image,
+	contentType string,
+) ([]byte, error) {
+	var buf bytes.Buffer
+	switch contentType {
+	case "image/jpeg":
+		err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+		if err != nil {
+			return nil, err
+		}
+	case "image/png":
+		err := png.Encode(&buf, img)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return enc.Encode(ctx, img, contentType)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// FallbackType returns the format of the variants served to browsers without
+// AVIF or WebP support.
+func FallbackType(path string) string {
+	switch GetContentType(path) {
+	case "image/png":
+		return "image/png"
+	default:
+		return "image/jpeg"
+	}
+}
+
+// variantExt returns the file extension of a variant content type.
+func variantExt(contentType string) string {
+	switch contentType {
+	case "image/avif":
+		return ".avif"
+	case "image/webp":
+		return ".webp"
+	case "image/png":
+		return ".png"
+	default:
+		return ".jpg"
+	}
+}
@@ -0,0 +1,172 @@
+package assets_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/conneroisu/conneroh.com/internal/assets"
+	"github.com/rotisserie/eris"
+)
+
+// stubEncoder encodes WebP as a placeholder naming the width and reports
+// every other format unsupported, or fails with err if it is set.
+type stubEncoder struct {
+	err error
+}
+
+func (e stubEncoder) Encode(_ context.Context, img image.Image, contentType string) ([]byte, error) {
+	switch {
+	case e.err != nil:
+		return nil, e.err
+	case contentType == "image/webp":
+		return []byte("webp " + strconv.Itoa(img.Bounds().Dx())), nil
+	default:
+		return nil, eris.Wrap(assets.ErrUnsupportedFormat, contentType)
+	}
+}
+
+// encoded returns an image of the given size encoded as PNG or, if jpg is
+// true, as JPEG.
+func encoded(t *testing.T, width, height int, jpg bool) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	var (
+		buf bytes.Buffer
+		err error
+	)
+	if jpg {
+		err = jpeg.Encode(&buf, img, nil)
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestMakeVariants(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		width  int
+		height int
+		jpg    bool
+		// want lists the key, width and height of every variant.
+		want []string
+	}{
+		{
+			name:  "Narrower widths only",
+			path:  "banner.png",
+			width: 1000, height: 500,
+			want: []string{
+				"banner.png 1000x500",
+				"banner.png.480w.png 480x240",
+				"banner.png.480w.webp 480x240",
+				"banner.png.960w.png 960x480",
+				"banner.png.960w.webp 960x480",
+				"banner.png.1000w.webp 1000x500",
+			},
+		},
+		{
+			name:  "JPEG falls back to JPEG",
+			path:  "banner.jpg",
+			width: 960, height: 960,
+			jpg: true,
+			want: []string{
+				"banner.jpg 960x960",
+				"banner.jpg.480w.jpg 480x480",
+				"banner.jpg.480w.webp 480x480",
+				"banner.jpg.960w.webp 960x960",
+			},
+		},
+		{
+			name:  "Narrower than every width",
+			path:  "icon.png",
+			width: 64, height: 32,
+			want: []string{
+				"icon.png 64x32",
+				"icon.png.64w.webp 64x32",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			variants, err := assets.MakeVariants(
+				context.Background(),
+				stubEncoder{},
+				tt.path,
+				encoded(t, tt.width, tt.height, tt.jpg),
+			)
+			if err != nil {
+				t.Fatalf("MakeVariants() error = %v", err)
+			}
+			got := make([]string, 0, len(variants))
+			for _, v := range variants {
+				got = append(got, v.Key+" "+strconv.Itoa(v.Width)+"x"+strconv.Itoa(v.Height))
+				if v.Path != tt.path {
+					t.Errorf("MakeVariants() %s path = %s, want %s", v.Key, v.Path, tt.path)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("MakeVariants() = %v, want %v", got, tt.want)
+			}
+			if len(variants) > 0 && variants[0].Data != nil {
+				t.Error("MakeVariants() original has data, want none")
+			}
+			for _, v := range variants[1:] {
+				if len(v.Data) == 0 {
+					t.Errorf("MakeVariants() %s has no data", v.Key)
+				}
+				if v.ContentType == "image/webp" && string(v.Data) != "webp "+strconv.Itoa(v.Width) {
+					t.Errorf("MakeVariants() %s = %q, want the encoder's output", v.Key, v.Data)
+				}
+			}
+		})
+	}
+}
+
+func TestMakeVariantsErrors(t *testing.T) {
+	failure := errors.New("encoder crashed")
+	_, err := assets.MakeVariants(
+		context.Background(),
+		stubEncoder{err: failure},
+		"banner.png",
+		encoded(t, 100, 100, false),
+	)
+	if !errors.Is(err, failure) {
+		t.Errorf("MakeVariants() error = %v, want %v", err, failure)
+	}
+	_, err = assets.MakeVariants(context.Background(), stubEncoder{}, "banner.png", []byte("not an image"))
+	if err == nil {
+		t.Error("MakeVariants() of garbage error = nil, want an error")
+	}
+}
+
+func TestVariantKey(t *testing.T) {
+	tests := []struct {
+		path        string
+		width       int
+		contentType string
+		want        string
+	}{
+		{path: "banner.png", width: 480, contentType: "image/webp", want: "banner.png.480w.webp"},
+		{path: "banner.jpg", width: 480, contentType: "image/webp", want: "banner.jpg.480w.webp"},
+		{path: "banner.png", width: 960, contentType: "image/avif", want: "banner.png.960w.avif"},
+		{path: "banner.png", width: 480, contentType: "image/png", want: "banner.png.480w.png"},
+		{path: "photos/a.jpeg", width: 1920, contentType: "image/jpeg", want: "photos/a.jpeg.1920w.jpg"},
+	}
+	for _, tt := range tests {
+		if got := assets.VariantKey(tt.path, tt.width, tt.contentType); got != tt.want {
+			t.Errorf("VariantKey(%q, %d, %q) = %q, want %q", tt.path, tt.width, tt.contentType, got, tt.want)
+		}
+	}
+}
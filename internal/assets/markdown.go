@@ -16,6 +16,7 @@ import (
 	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
 
 	"go.abhg.dev/goldmark/anchor"
 	"go.abhg.dev/goldmark/frontmatter"
@@ -25,9 +26,36 @@ import (
 )
 
 // NewMD creates a new markdown parser.
+//
+// By default math and mermaid diagrams are left for MathJax and mermaid to
+// render in the browser, see WithRenderer.
 func NewMD(
 	fs afero.Fs,
+	opts ...MDOption,
 ) goldmark.Markdown {
+	var (
+		cfg     mdConfig
+		math    goldmark.Extender = mathjax.MathJax
+		diagram                   = &mermaid.Extender{
+			RenderMode: mermaid.RenderModeClient,
+			// Pages load mermaid themselves, with the nonce their
+			// Content Security Policy requires.
+			NoScript: true,
+		}
+	)
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.renderer != nil {
+		math = &serverMath{r: cfg.renderer}
+		diagram = &mermaid.Extender{
+			RenderMode: mermaid.RenderModeServer,
+			Compiler:   cfg.renderer,
+		}
+	}
+
+	res := newResolver(fs)
+
 	return goldmark.New(goldmark.WithParserOptions(
 		parser.WithAutoHeadingID(),
 		parser.WithAttribute(),
@@ -39,8 +67,13 @@ func NewMD(
 		),
 		goldmark.WithExtensions(
 			&wikilink.Extender{
-				Resolver: newResolver(fs),
+				Resolver: res,
 			},
+			&videoExtension{r: &videoRenderer{
+				fs:     fs,
+				lookup: cfg.media,
+				links:  &wikilink.Renderer{Resolver: res},
+			}},
 			callout.ObsidianCallout,
 			extension.GFM,
 			extension.Footnote,
@@ -48,7 +81,7 @@ func NewMD(
 			extension.Table,
 			extension.TaskList,
 			extension.DefinitionList,
-			mathjax.MathJax,
+			math,
 			extension.NewTypographer(
 				extension.WithTypographicSubstitutions(
 					extension.TypographicSubstitutions{
@@ -66,9 +99,7 @@ func NewMD(
 					"class": "anchor permalink p-4",
 				},
 			},
-			&mermaid.Extender{
-				RenderMode: mermaid.RenderModeClient,
-			},
+			diagram,
 			&frontmatter.Extender{
 				Formats: []frontmatter.Format{frontmatter.YAML},
 			},
@@ -113,7 +144,7 @@ func (r *resolver) ResolveWikilink(n *wikilink.Node) ([]byte, error) {
 		return nil, err
 	}
 
-	return []byte(BucketPath(targetStr)), nil
+	return []byte(AssetURL(targetStr)), nil
 }
 
 // ParseMarkdown parses a markdown document.
@@ -137,8 +168,15 @@ func ParseMarkdown(md goldmark.Markdown, item DirMatchItem) (*Doc, error) {
 	// Create a new parser context
 	pCtx := parser.NewContext()
 
-	// Convert markdown
-	err = md.Convert([]byte(item.Content), &buf, parser.WithContext(pCtx))
+	// Parse markdown
+	source := []byte(item.Content)
+	root := md.Parser().Parse(text.NewReader(source), parser.WithContext(pCtx))
+	doc.TOC, doc.WordCount = Outline(root, source)
+	doc.MediaAlts = MediaReferences(root, source)
+	doc.ReadingTime = ReadingTime(doc.WordCount)
+
+	// Render markdown
+	err = md.Renderer().Render(&buf, source, root)
 	if err != nil {
 		return nil, eris.Wrapf(err, "failed to convert markdown: %s", doc.Path)
 	}
@@ -154,6 +192,20 @@ func ParseMarkdown(md goldmark.Markdown, item DirMatchItem) (*Doc, error) {
 		return nil, eris.Wrapf(err, "failed to decode frontmatter: %s", doc.Path)
 	}
 
+	if doc.SeriesTitle != "" {
+		doc.SeriesSlug = SlugifyTitle(doc.SeriesTitle)
+	}
+
+	// The banner is described by the document it illustrates
+	if doc.BannerPath != "" {
+		addReference(doc.MediaAlts, doc.BannerPath, doc.Title)
+	}
+
+	// Fall back to when the file last changed
+	if doc.UpdatedAt.IsZero() {
+		doc.UpdatedAt = CustomTime{item.ModTime}
+	}
+
 	doc.Content = buf.String()
 
 	return doc, nil
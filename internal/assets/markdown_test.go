@@ -0,0 +1,92 @@
+package assets_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/conneroisu/conneroh.com/internal/assets"
+	"github.com/spf13/afero"
+	"go.abhg.dev/goldmark/mermaid"
+)
+
+// stubRenderer renders math and diagrams to fixed markup without any
+// external executables.
+type stubRenderer struct{}
+
+func (stubRenderer) Compile(
+	_ context.Context,
+	req *mermaid.CompileRequest,
+) (*mermaid.CompileResponse, error) {
+	return &mermaid.CompileResponse{
+		SVG: "<svg data-mermaid>" + strings.TrimSpace(req.Source) + "</svg>",
+	}, nil
+}
+
+func (stubRenderer) RenderMath(
+	_ context.Context,
+	tex string,
+	display bool,
+) ([]byte, error) {
+	if display {
+		return []byte("<math display=\"block\">" + strings.TrimSpace(tex) + "</math>"), nil
+	}
+
+	return []byte("<math>" + tex + "</math>"), nil
+}
+
+func TestParseMarkdownRenderModes(t *testing.T) {
+	const content = "---\ntitle: Test\n---\n" +
+		"Euler: $e^{i\\pi} + 1 = 0$\n\n" +
+		"$$\na^2 + b^2 = c^2\n$$\n\n" +
+		"```mermaid\ngraph TD; A-->B\n```\n"
+
+	tests := []struct {
+		name     string
+		opts     []assets.MDOption
+		contains []string
+		excludes []string
+	}{
+		{
+			name: "Client rendering",
+			contains: []string{
+				`<span class="math inline">`,
+				`<span class="math display">`,
+				`<pre class="mermaid">`,
+			},
+			excludes: []string{"<math", "<svg data-mermaid>", "<script"},
+		},
+		{
+			name: "Server rendering",
+			opts: []assets.MDOption{assets.WithRenderer(stubRenderer{})},
+			contains: []string{
+				`<span class="tex inline"><math>e^{i\pi} + 1 = 0</math></span>`,
+				`<div class="tex display"><math display="block">a^2 + b^2 = c^2</math></div>`,
+				"<svg data-mermaid>graph TD; A-->B</svg>",
+			},
+			excludes: []string{`class="math `, `<pre class="mermaid">`, "<script"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md := assets.NewMD(afero.NewMemMapFs(), tt.opts...)
+			doc, err := assets.ParseMarkdown(md, assets.DirMatchItem{
+				Path:    "posts/test.md",
+				Content: content,
+			})
+			if err != nil {
+				t.Fatalf("ParseMarkdown() error = %v", err)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(doc.Content, want) {
+					t.Errorf("ParseMarkdown() content = %s, want it to contain %s", doc.Content, want)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(doc.Content, unwanted) {
+					t.Errorf("ParseMarkdown() content = %s, want it to not contain %s", doc.Content, unwanted)
+				}
+			}
+		})
+	}
+}
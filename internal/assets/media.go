@@ -0,0 +1,458 @@
+package assets
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"slices"
+
+	// Register the gif decoder for describing gifs.
+	_ "image/gif"
+
+	"github.com/rotisserie/eris"
+	"github.com/spf13/afero"
+	"github.com/uptrace/bun"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/tiff"
+)
+
+const (
+	// placeholderWidth is the width of the blurry placeholder rendition.
+	placeholderWidth = 16
+	// paletteSample is the size of the thumbnail the dominant color is
+	// picked from.
+	paletteSample = 32
+)
+
+// IsRasterImage returns true if the asset at path is a raster image that
+// DescribeAsset can measure.
+func IsRasterImage(path string) bool {
+	return IsVariantSource(path) || GetContentType(path) == "image/gif"
+}
+
+// ProcessAsset records the metadata of the asset at path, stripping
+// sensitive metadata from raster images first.
+//
+// It returns the data that should be published in place of data.
+func ProcessAsset(
+	ctx context.Context,
+	db *bun.DB,
+	path string,
+	data []byte,
+) ([]byte, error) {
+	var err error
+	if IsRasterImage(path) {
+		data, err = StripMetadata(path, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	media, err := DescribeAsset(path, data)
+	if err != nil {
+		return nil, err
+	}
+	err = UpsertMedia(ctx, db, media)
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// DescribeAsset returns the content type and size of an asset along with the
+// dimensions, dominant color and placeholder of raster images.
+func DescribeAsset(path string, data []byte) (*Media, error) {
+	media := &Media{
+		Path:        path,
+		ContentType: GetContentType(path),
+		Size:        int64(len(data)),
+	}
+	if !IsRasterImage(path) {
+		return media, nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, eris.Wrapf(err, "failed to decode image: %s", path)
+	}
+	media.Placeholder, err = lqip(img)
+	if err != nil {
+		return nil, eris.Wrapf(err, "failed to create placeholder: %s", path)
+	}
+	media.Width = img.Bounds().Dx()
+	media.Height = img.Bounds().Dy()
+	media.DominantColor = dominantColor(img)
+
+	return media, nil
+}
+
+// MissingMedia returns the assets that have no media row or manifest entry
+// yet, such as those published before either was recorded.
+func MissingMedia(
+	ctx context.Context,
+	fs afero.Fs,
+	db *bun.DB,
+) ([]DirMatchItem, error) {
+	var known []string
+	err := db.NewSelect().
+		Model((*Media)(nil)).
+		Column("media.path").
+		Join("JOIN manifest ON manifest.path = media.path").
+		Scan(ctx, &known)
+	if err != nil {
+		return nil, eris.Wrap(err, "failed to list media")
+	}
+	var missing []DirMatchItem
+	err = afero.Walk(
+		fs,
+		AssetsLoc,
+		func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || slices.Contains(known, Pathify(p)) {
+				return nil
+			}
+			item, err := MatchItem(fs, p)
+			if err != nil {
+				return err
+			}
+			missing = append(missing, item)
+
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, eris.Wrap(err, "failed to walk assets")
+	}
+
+	return missing, nil
+}
+
+// StripMetadata removes EXIF, XMP and textual metadata, which can include GPS
+// coordinates and camera serial numbers, from an image.
+//
+// JPEG, PNG and WebP images are stripped without re-encoding unless a JPEG
+// relies on its EXIF orientation, in which case the orientation is applied to
+// the pixels first. TIFF images are re-encoded. Other formats are returned as
+// is.
+func StripMetadata(path string, data []byte) ([]byte, error) {
+	switch GetContentType(path) {
+	case "image/jpeg":
+		return stripJPEG(data)
+	case "image/png":
+		return stripPNG(data)
+	case "image/webp":
+		return stripWebP(data)
+	case "image/tiff":
+		img, err := tiff.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, eris.Wrapf(err, "failed to decode tiff: %s", path)
+		}
+		var buf bytes.Buffer
+		err = tiff.Encode(&buf, img, &tiff.Options{Compression: tiff.Deflate})
+		if err != nil {
+			return nil, eris.Wrapf(err, "failed to encode tiff: %s", path)
+		}
+
+		return buf.Bytes(), nil
+	default:
+		return data, nil
+	}
+}
+
+// stripJPEG keeps only the segments of a JPEG needed to decode it: JFIF
+// (APP0), ICC color profiles (APP2), Adobe color transforms (APP14), the
+// quantization, Huffman and arithmetic coding tables, the frame headers, the
+// restart interval and the scans. Everything else, including EXIF, XMP, MPF
+// previews, IPTC and comments, is dropped.
+func stripJPEG(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, eris.New("not a jpeg")
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+	orientation := 1
+segments:
+	for i := 2; i < len(data); {
+		if data[i] != 0xFF {
+			return nil, eris.Errorf("invalid jpeg marker at %d", i)
+		}
+		// Markers may be preceded by any number of 0xFF fill bytes.
+		for i+1 < len(data) && data[i+1] == 0xFF {
+			i++
+		}
+		if i+1 >= len(data) {
+			return nil, eris.New("truncated jpeg marker")
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xDA:
+			// Start of scan: the entropy coded data follows until the end.
+			out.Write(data[i:])
+
+			break segments
+		case marker == 0xD9:
+			out.Write(data[i : i+2])
+
+			break segments
+		case marker == 0x01 || marker >= 0xD0 && marker <= 0xD7:
+			// Standalone markers carry no length.
+			i += 2
+
+			continue
+		}
+		if i+4 > len(data) {
+			return nil, eris.New("truncated jpeg segment")
+		}
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end > len(data) || end < i+4 {
+			return nil, eris.New("truncated jpeg segment")
+		}
+		payload := data[i+4 : end]
+		switch {
+		case marker == 0xE1:
+			if o := exifOrientation(payload); o > 1 {
+				orientation = o
+			}
+		case keepJPEGSegment(marker, payload):
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+	if orientation == 1 {
+		return out.Bytes(), nil
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		return nil, eris.Wrap(err, "failed to decode jpeg")
+	}
+	var buf bytes.Buffer
+	err = jpeg.Encode(&buf, orient(img, orientation), &jpeg.Options{Quality: 92})
+	if err != nil {
+		return nil, eris.Wrap(err, "failed to encode jpeg")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// keepJPEGSegment reports whether stripJPEG keeps the segment with the given
+// marker and payload.
+func keepJPEGSegment(marker byte, payload []byte) bool {
+	switch {
+	case marker == 0xE0, marker == 0xEE:
+		// JFIF and Adobe.
+		return true
+	case marker == 0xE2:
+		// APP2 also carries MPF, which embeds previews with their own EXIF.
+		return bytes.HasPrefix(payload, []byte("ICC_PROFILE\x00"))
+	case marker == 0xDB, marker == 0xC4, marker == 0xCC, marker == 0xDD:
+		// DQT, DHT, DAC and DRI.
+		return true
+	case marker >= 0xC0 && marker <= 0xCF:
+		// SOF0 to SOF15; DHT and DAC share the range and are matched above.
+		return true
+	default:
+		return false
+	}
+}
+
+// exifOrientation returns the orientation tag of an APP1 EXIF payload or 0.
+func exifOrientation(payload []byte) int {
+	tiffData, ok := bytes.CutPrefix(payload, []byte("Exif\x00\x00"))
+	if !ok || len(tiffData) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiffData[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+	ifd := int(order.Uint32(tiffData[4:]))
+	if ifd+2 > len(tiffData) {
+		return 0
+	}
+	entries := int(order.Uint16(tiffData[ifd:]))
+	for e := range entries {
+		entry := ifd + 2 + e*12
+		if entry+12 > len(tiffData) {
+			return 0
+		}
+		if order.Uint16(tiffData[entry:]) == 0x0112 {
+			return int(order.Uint16(tiffData[entry+8:]))
+		}
+	}
+
+	return 0
+}
+
+// orient applies an EXIF orientation to img.
+func orient(img image.Image, orientation int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if orientation >= 5 {
+		w, h = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range b.Dy() {
+		for x := range b.Dx() {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = b.Dx()-1-x, y
+			case 3:
+				dx, dy = b.Dx()-1-x, b.Dy()-1-y
+			case 4:
+				dx, dy = x, b.Dy()-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = b.Dy()-1-y, x
+			case 7:
+				dx, dy = b.Dy()-1-y, b.Dx()-1-x
+			case 8:
+				dx, dy = y, b.Dx()-1-x
+			default:
+				dx, dy = x, y
+			}
+			dst.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+
+	return dst
+}
+
+// stripPNG drops the eXIf, textual and timestamp chunks of a PNG.
+func stripPNG(data []byte) ([]byte, error) {
+	const signature = "\x89PNG\r\n\x1a\n"
+	if !bytes.HasPrefix(data, []byte(signature)) {
+		return nil, eris.New("not a png")
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.WriteString(signature)
+	for i := len(signature); i+8 <= len(data); {
+		size := int(binary.BigEndian.Uint32(data[i:]))
+		end := i + 12 + size
+		if end > len(data) {
+			return nil, eris.New("truncated png chunk")
+		}
+		switch string(data[i+4 : i+8]) {
+		case "eXIf", "tEXt", "zTXt", "iTXt", "tIME":
+		default:
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+
+	return out.Bytes(), nil
+}
+
+// stripWebP drops the EXIF and XMP chunks of an extended WebP.
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, eris.New("not a webp")
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:12])
+	for i := 12; i+8 <= len(data); {
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + size + size%2
+		if end > len(data) {
+			return nil, eris.New("truncated webp chunk")
+		}
+		chunk := data[i:end]
+		switch string(data[i : i+4]) {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk = bytes.Clone(chunk)
+			// Clear the EXIF and XMP presence flags.
+			chunk[8] &^= 0x08 | 0x04
+			out.Write(chunk)
+		default:
+			out.Write(chunk)
+		}
+		i = end
+	}
+	stripped := out.Bytes()
+	binary.LittleEndian.PutUint32(stripped[4:], uint32(len(stripped)-8)) //nolint:gosec
+
+	return stripped, nil
+}
+
+// dominantColor returns the most common color of img as a CSS hex color.
+//
+// Colors are bucketed at 4 bits per channel and the winning bucket is
+// averaged.
+func dominantColor(img image.Image) string {
+	type bucket struct{ r, g, b, n int }
+	sample := image.NewRGBA(image.Rect(0, 0, paletteSample, paletteSample))
+	draw.ApproxBiLinear.Scale(sample, sample.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	var (
+		buckets = map[uint16]*bucket{}
+		best    *bucket
+	)
+	for y := range paletteSample {
+		for x := range paletteSample {
+			c := sample.RGBAAt(x, y)
+			if c.A < 128 {
+				continue
+			}
+			key := uint16(c.R>>4)<<8 | uint16(c.G>>4)<<4 | uint16(c.B>>4)
+			b, ok := buckets[key]
+			if !ok {
+				b = &bucket{}
+				buckets[key] = b
+			}
+			b.r += int(c.R)
+			b.g += int(c.G)
+			b.b += int(c.B)
+			b.n++
+			if best == nil || b.n > best.n {
+				best = b
+			}
+		}
+	}
+	if best == nil {
+		return "transparent"
+	}
+
+	return hexColor(color.RGBA{
+		R: uint8(best.r / best.n), //nolint:gosec
+		G: uint8(best.g / best.n), //nolint:gosec
+		B: uint8(best.b / best.n), //nolint:gosec
+		A: 0xFF,
+	})
+}
+
+// hexColor formats c as a CSS hex color.
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// lqip returns a tiny, low quality rendition of img as a data URI.
+func lqip(img image.Image) (string, error) {
+	b := img.Bounds()
+	height := max(1, b.Dy()*placeholderWidth/max(1, b.Dx()))
+	small := image.NewRGBA(image.Rect(0, 0, placeholderWidth, height))
+	draw.ApproxBiLinear.Scale(small, small.Bounds(), img, b, draw.Src, nil)
+
+	var buf bytes.Buffer
+	err := png.Encode(&buf, small)
+	if err != nil {
+		return "", err
+	}
+
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
@@ -0,0 +1,311 @@
+package assets_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/conneroisu/conneroh.com/internal/assets"
+)
+
+// gpsLatitude is the latitude 41°52'30" written into the GPS IFD of the
+// fixtures, as three big endian rationals.
+var gpsLatitude = []byte{
+	0, 0, 0, 41, 0, 0, 0, 1,
+	0, 0, 0, 52, 0, 0, 0, 1,
+	0, 0, 0, 30, 0, 0, 0, 1,
+}
+
+// exifPayload returns an EXIF APP1 payload, as a camera writes it, whose
+// IFD0 holds orientation and points at a GPS IFD with a latitude.
+func exifPayload(order binary.ByteOrder, orientation uint16) []byte {
+	const (
+		ifd0   = 8
+		gpsIFD = ifd0 + 2 + 2*12 + 4
+		gpsVal = gpsIFD + 2 + 2*12 + 4
+	)
+	b := make([]byte, gpsVal, gpsVal+len(gpsLatitude))
+	if order == binary.LittleEndian {
+		copy(b, "II")
+	} else {
+		copy(b, "MM")
+	}
+	order.PutUint16(b[2:], 42)
+	order.PutUint32(b[4:], ifd0)
+	entry := func(at int, tag, typ uint16, count uint32) {
+		order.PutUint16(b[at:], tag)
+		order.PutUint16(b[at+2:], typ)
+		order.PutUint32(b[at+4:], count)
+	}
+
+	order.PutUint16(b[ifd0:], 2)
+	entry(ifd0+2, 0x0112, 3, 1) // Orientation
+	order.PutUint16(b[ifd0+2+8:], orientation)
+	entry(ifd0+14, 0x8825, 4, 1) // GPSInfo
+	order.PutUint32(b[ifd0+14+8:], gpsIFD)
+
+	order.PutUint16(b[gpsIFD:], 2)
+	entry(gpsIFD+2, 0x0001, 2, 2) // GPSLatitudeRef
+	copy(b[gpsIFD+2+8:], "N")
+	entry(gpsIFD+14, 0x0002, 5, 3) // GPSLatitude
+	order.PutUint32(b[gpsIFD+14+8:], gpsVal)
+	if order == binary.LittleEndian {
+		lat := bytes.Clone(gpsLatitude)
+		for i := 0; i < len(lat); i += 4 {
+			binary.LittleEndian.PutUint32(lat[i:], binary.BigEndian.Uint32(lat[i:]))
+		}
+		b = append(b, lat...)
+	} else {
+		b = append(b, gpsLatitude...)
+	}
+
+	return append([]byte("Exif\x00\x00"), b...)
+}
+
+// segment encodes a JPEG marker segment.
+func segment(marker byte, payload []byte) []byte {
+	seg := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2)) //nolint:gosec
+
+	return append(seg, payload...)
+}
+
+// halves returns a 32x16 image whose left half is red and right half blue.
+func halves() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 32, 16))
+	for y := range 16 {
+		for x := range 32 {
+			c := color.RGBA{R: 0xFF, A: 0xFF}
+			if x >= 16 {
+				c = color.RGBA{B: 0xFF, A: 0xFF}
+			}
+			img.Set(x, y, c)
+		}
+	}
+
+	return img
+}
+
+// jpegWith encodes halves as a JPEG and inserts segments after its start of
+// image marker.
+func jpegWith(t *testing.T, segments ...[]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, halves(), &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	return append(append(bytes.Clone(data[:2]), bytes.Join(segments, nil)...), data[2:]...)
+}
+
+// pngChunk encodes a PNG chunk.
+func pngChunk(typ string, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data))) //nolint:gosec
+	chunk = append(chunk, typ...)
+	chunk = append(chunk, data...)
+
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+// webpChunk encodes a RIFF chunk of a WebP.
+func webpChunk(typ string, data []byte) []byte {
+	chunk := append([]byte(typ), binary.LittleEndian.AppendUint32(nil, uint32(len(data)))...) //nolint:gosec
+	chunk = append(chunk, data...)
+	if len(data)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+
+	return chunk
+}
+
+// isRed reports whether c is closer to red than to blue.
+func isRed(c color.Color) bool {
+	r, _, b, _ := c.RGBA()
+
+	return r > b
+}
+
+func TestStripMetadataJPEG(t *testing.T) {
+	icc := append([]byte("ICC_PROFILE\x00\x01\x01"), "profile"...)
+	mpf := append([]byte("MPF\x00"), exifPayload(binary.BigEndian, 1)...)
+	tests := []struct {
+		name     string
+		data     []byte
+		wantSize image.Point
+		// wantTopRed is whether the top left of the image is red.
+		wantTopRed bool
+		wantKept   [][]byte
+	}{
+		{
+			name: "gps is dropped without re-encoding",
+			data: jpegWith(t,
+				segment(0xE1, exifPayload(binary.BigEndian, 1)),
+				segment(0xE2, icc),
+			),
+			wantSize:   image.Pt(32, 16),
+			wantTopRed: true,
+			wantKept:   [][]byte{icc},
+		},
+		{
+			name: "mpf previews, iptc and comments are dropped",
+			data: jpegWith(t,
+				segment(0xE2, mpf),
+				segment(0xED, []byte("Photoshop 3.0\x00")),
+				segment(0xFE, []byte("taken at 41N")),
+				segment(0xE5, []byte("vendor")),
+			),
+			wantSize:   image.Pt(32, 16),
+			wantTopRed: true,
+		},
+		{
+			name: "fill bytes before markers",
+			data: jpegWith(t,
+				[]byte{0xFF, 0xFF},
+				segment(0xE1, exifPayload(binary.LittleEndian, 1)),
+				[]byte{0xFF},
+			),
+			wantSize:   image.Pt(32, 16),
+			wantTopRed: true,
+		},
+		{
+			name:       "big endian orientation is applied",
+			data:       jpegWith(t, segment(0xE1, exifPayload(binary.BigEndian, 6))),
+			wantSize:   image.Pt(16, 32),
+			wantTopRed: true,
+		},
+		{
+			name:       "little endian orientation is applied",
+			data:       jpegWith(t, segment(0xE1, exifPayload(binary.LittleEndian, 3))),
+			wantSize:   image.Pt(32, 16),
+			wantTopRed: false,
+		},
+		{
+			name:       "mirrored orientation is applied",
+			data:       jpegWith(t, segment(0xE1, exifPayload(binary.BigEndian, 2))),
+			wantSize:   image.Pt(32, 16),
+			wantTopRed: false,
+		},
+		{
+			name:       "counterclockwise orientation is applied",
+			data:       jpegWith(t, segment(0xE1, exifPayload(binary.BigEndian, 8))),
+			wantSize:   image.Pt(16, 32),
+			wantTopRed: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := assets.StripMetadata("a.jpg", tt.data)
+			if err != nil {
+				t.Fatalf("StripMetadata() error = %v", err)
+			}
+			for _, leak := range [][]byte{[]byte("Exif\x00\x00"), gpsLatitude, []byte("MPF"), []byte("Photoshop"), []byte("41N"), []byte("vendor")} {
+				if bytes.Contains(got, leak) {
+					t.Errorf("StripMetadata() kept %q", leak)
+				}
+			}
+			for _, kept := range tt.wantKept {
+				if !bytes.Contains(got, kept) {
+					t.Errorf("StripMetadata() dropped %q", kept)
+				}
+			}
+			img, err := jpeg.Decode(bytes.NewReader(got))
+			if err != nil {
+				t.Fatalf("jpeg.Decode() error = %v", err)
+			}
+			if size := img.Bounds().Size(); size != tt.wantSize {
+				t.Errorf("StripMetadata() size = %v, want %v", size, tt.wantSize)
+			}
+			if red := isRed(img.At(2, 2)); red != tt.wantTopRed {
+				t.Errorf("StripMetadata() top left red = %v, want %v", red, tt.wantTopRed)
+			}
+		})
+	}
+}
+
+func TestStripMetadataInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		data []byte
+	}{
+		{name: "not a jpeg", path: "a.jpg", data: []byte("GIF89a")},
+		{name: "garbage after jpeg marker", path: "a.jpg", data: []byte{0xFF, 0xD8, 0x00, 0x01, 0x02, 0x03}},
+		{name: "truncated jpeg segment", path: "a.jpg", data: []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x10, 0x00, 0x00}},
+		{name: "not a png", path: "a.png", data: []byte("\xFF\xD8\xFF")},
+		{name: "not a webp", path: "a.webp", data: []byte("RIFF\x00\x00\x00\x00WAVE")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := assets.StripMetadata(tt.path, tt.data); err == nil {
+				t.Errorf("StripMetadata() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestStripMetadataPNG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, halves()); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	// Insert the metadata chunks after IHDR.
+	ihdr := 8 + 12 + 13
+	var meta []byte
+	for _, chunk := range [][]byte{
+		pngChunk("eXIf", exifPayload(binary.BigEndian, 1)[6:]),
+		pngChunk("tEXt", []byte("Comment\x00taken at 41N")),
+		pngChunk("iTXt", []byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00<x:xmpmeta/>")),
+		pngChunk("tIME", []byte{0x07, 0xE9, 1, 2, 3, 4, 5}),
+	} {
+		meta = append(meta, chunk...)
+	}
+	data = append(append(bytes.Clone(data[:ihdr]), meta...), data[ihdr:]...)
+
+	got, err := assets.StripMetadata("a.png", data)
+	if err != nil {
+		t.Fatalf("StripMetadata() error = %v", err)
+	}
+	for _, leak := range []string{"eXIf", "tEXt", "iTXt", "tIME", "41N"} {
+		if bytes.Contains(got, []byte(leak)) {
+			t.Errorf("StripMetadata() kept %q", leak)
+		}
+	}
+	if !bytes.Equal(got, buf.Bytes()) {
+		t.Errorf("StripMetadata() = %d bytes, want the %d bytes of the image", len(got), buf.Len())
+	}
+}
+
+func TestStripMetadataWebP(t *testing.T) {
+	vp8x := make([]byte, 10)
+	vp8x[0] = 0x08 | 0x04 | 0x10 // EXIF, XMP and alpha
+	body := bytes.Join([][]byte{
+		[]byte("WEBP"),
+		webpChunk("VP8X", vp8x),
+		webpChunk("VP8L", []byte("pixels")),
+		webpChunk("EXIF", exifPayload(binary.LittleEndian, 1)[6:]),
+		webpChunk("XMP ", []byte("<x:xmpmeta>41N</x:xmpmeta>")),
+	}, nil)
+	data := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...) //nolint:gosec
+	data = append(data, body...)
+
+	got, err := assets.StripMetadata("a.webp", data)
+	if err != nil {
+		t.Fatalf("StripMetadata() error = %v", err)
+	}
+	want := bytes.Join([][]byte{
+		[]byte("WEBP"),
+		webpChunk("VP8X", append([]byte{0x10}, vp8x[1:]...)),
+		webpChunk("VP8L", []byte("pixels")),
+	}, nil)
+	want = append(append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(want)))...), want...) //nolint:gosec
+	if !bytes.Equal(got, want) {
+		t.Errorf("StripMetadata() = %q, want %q", got, want)
+	}
+}
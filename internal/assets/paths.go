@@ -2,8 +2,13 @@ package assets
 
 import (
 	"fmt"
+	"net/url"
 	"path/filepath"
 	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/rotisserie/eris"
 )
 
 const (
@@ -20,8 +25,89 @@ const (
 	ProjectsLoc = "projects/"
 	// EmploymentsLoc is the location of the employments relative to the vault.
 	EmploymentsLoc = "employments/"
+
+	// DefaultAssetBaseURL is the bucket assets are served from unless
+	// configured otherwise.
+	DefaultAssetBaseURL = "https://conneroisu.fly.storage.tigris.dev/"
+	// AssetBaseURLVar is the environment variable overriding the URL assets
+	// are served from, e.g. a fork's bucket or a local MinIO.
+	AssetBaseURLVar = "ASSET_BASE_URL"
+	// LocalAssetsVar is the environment variable that, when "true", serves
+	// assets from the vault instead of the bucket.
+	LocalAssetsVar = "LOCAL_ASSETS"
 )
 
+// assetBase is the URL every asset URL is generated from.
+var assetBase = struct {
+	sync.RWMutex
+	url   string
+	local bool
+}{
+	url: DefaultAssetBaseURL,
+}
+
+// ConfigureAssets sets the asset base URL from the environment.
+//
+// In local mode assets are linked by their paths under the site itself, where
+// the server is expected to serve the vault's assets directory.
+func ConfigureAssets(getenv func(string) string) error {
+	if getenv(LocalAssetsVar) == "true" {
+		UseLocalAssets()
+
+		return nil
+	}
+	if base := getenv(AssetBaseURLVar); base != "" {
+		return SetAssetBaseURL(base)
+	}
+
+	return nil
+}
+
+// SetAssetBaseURL sets the absolute URL the assets directory of the bucket is
+// served under.
+func SetAssetBaseURL(base string) error {
+	err := ValidateAssetBaseURL(base)
+	if err != nil {
+		return err
+	}
+	assetBase.Lock()
+	defer assetBase.Unlock()
+	assetBase.url = strings.TrimSuffix(base, "/") + "/"
+	assetBase.local = false
+
+	return nil
+}
+
+// ValidateAssetBaseURL returns an error unless base is an absolute http(s)
+// URL.
+func ValidateAssetBaseURL(base string) error {
+	u, err := url.Parse(base)
+	if err != nil {
+		return eris.Wrapf(err, "invalid asset base URL: %s", base)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return eris.Errorf("asset base URL must be an absolute http(s) URL: %s", base)
+	}
+
+	return nil
+}
+
+// UseLocalAssets links assets by their paths under the site itself.
+func UseLocalAssets() {
+	assetBase.Lock()
+	defer assetBase.Unlock()
+	assetBase.url = "/"
+	assetBase.local = true
+}
+
+// LocalAssets returns true if assets are served from the vault.
+func LocalAssets() bool {
+	assetBase.RLock()
+	defer assetBase.RUnlock()
+
+	return assetBase.local
+}
+
 // Pathify returns the slugified path of a document or media asset.
 func Pathify(s string) string {
 	var path string
@@ -66,6 +152,31 @@ func Slugify(s string) string {
 	panic(fmt.Errorf("failed to pathify %s", s))
 }
 
+// SlugifyTitle converts a human readable title into a URL slug.
+//
+// Runs of characters other than lowercase letters and digits become a single
+// hyphen, e.g. "Building a Compiler, Part 1" becomes "building-a-compiler-part-1".
+func SlugifyTitle(title string) string {
+	var (
+		b      strings.Builder
+		hyphen bool
+	)
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			hyphen = false
+
+			continue
+		}
+		hyphen = true
+	}
+
+	return b.String()
+}
+
 // Static mapping of file extensions to content types to avoid reflection.
 var contentTypes = map[string]string{
 	".jpg":   "image/jpeg",
@@ -74,6 +185,9 @@ var contentTypes = map[string]string{
 	".gif":   "image/gif",
 	".svg":   "image/svg+xml",
 	".webp":  "image/webp",
+	".avif":  "image/avif",
+	".tif":   "image/tiff",
+	".tiff":  "image/tiff",
 	".css":   "text/css",
 	".txt":   "text/plain",
 	".md":    "text/markdown",
@@ -85,6 +199,7 @@ var contentTypes = map[string]string{
 	".webm":  "video/webm",
 	".wav":   "audio/wav",
 	".mov":   "video/quicktime",
+	".vtt":   "text/vtt",
 	".ico":   "image/x-icon",
 	".woff":  "font/woff",
 	".woff2": "font/woff2",
@@ -102,9 +217,21 @@ func GetContentType(path string) string {
 	return "application/octet-stream"
 }
 
-// BucketPath returns the path to the bucket for a given file path.
+// HashedKey returns the content-addressed key of a file, e.g. "banner.png"
+// becomes "banner.3f2a9c1b7e4d.png", so that its URL changes whenever its
+// content does.
+func HashedKey(path string, data []byte) string {
+	ext := filepath.Ext(path)
+
+	return strings.TrimSuffix(path, ext) + "." + ComputeHash(data)[:12] + ext
+}
+
+// BucketPath returns the URL of the asset at path under the asset base URL.
 func BucketPath(path string) string {
-	return "https://conneroisu.fly.storage.tigris.dev/assets/" + path
+	assetBase.RLock()
+	defer assetBase.RUnlock()
+
+	return assetBase.url + AssetsLoc + path
 }
 
 func isVideoType(contentType string) bool {
@@ -0,0 +1,37 @@
+package assets
+
+import "time"
+
+// isPublished reports whether a document with the given publication state
+// is live at the given time.
+func isPublished(draft bool, publishAt *CustomTime, now time.Time) bool {
+	if draft {
+		return false
+	}
+
+	return publishAt == nil || !publishAt.After(now)
+}
+
+// IsPublished reports whether the post is reachable by readers at the given
+// time, i.e. it is not a draft and its publish date has passed.
+func (emb *Post) IsPublished(now time.Time) bool {
+	return isPublished(emb.Draft, emb.PublishAt, now)
+}
+
+// IsListed reports whether the post belongs in list pages, the home page,
+// feeds and search results at the given time.
+func (emb *Post) IsListed(now time.Time) bool {
+	return !emb.Unlisted && emb.IsPublished(now)
+}
+
+// IsPublished reports whether the project is reachable by readers at the
+// given time, i.e. it is not a draft and its publish date has passed.
+func (emb *Project) IsPublished(now time.Time) bool {
+	return isPublished(emb.Draft, emb.PublishAt, now)
+}
+
+// IsListed reports whether the project belongs in list pages, the home page,
+// feeds and search results at the given time.
+func (emb *Project) IsListed(now time.Time) bool {
+	return !emb.Unlisted && emb.IsPublished(now)
+}
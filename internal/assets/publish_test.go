@@ -0,0 +1,50 @@
+package assets_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/conneroisu/conneroh.com/internal/assets"
+)
+
+func TestPublication(t *testing.T) {
+	now := time.Date(2026, time.June, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *assets.CustomTime {
+		return &assets.CustomTime{Time: now.Add(d)}
+	}
+	tests := []struct {
+		name          string
+		draft         bool
+		unlisted      bool
+		publishAt     *assets.CustomTime
+		wantPublished bool
+		wantListed    bool
+	}{
+		{name: "Published", wantPublished: true, wantListed: true},
+		{name: "Draft", draft: true},
+		{name: "Draft with a past date", draft: true, publishAt: at(-time.Hour)},
+		{name: "Future date", publishAt: at(time.Hour)},
+		{name: "Past date", publishAt: at(-time.Hour), wantPublished: true, wantListed: true},
+		{name: "Publishing now", publishAt: at(0), wantPublished: true, wantListed: true},
+		{name: "Unlisted", unlisted: true, wantPublished: true},
+		{name: "Unlisted with a future date", unlisted: true, publishAt: at(time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			post := &assets.Post{Draft: tt.draft, Unlisted: tt.unlisted, PublishAt: tt.publishAt}
+			if got := post.IsPublished(now); got != tt.wantPublished {
+				t.Errorf("Post.IsPublished() = %v, want %v", got, tt.wantPublished)
+			}
+			if got := post.IsListed(now); got != tt.wantListed {
+				t.Errorf("Post.IsListed() = %v, want %v", got, tt.wantListed)
+			}
+			project := &assets.Project{Draft: tt.draft, Unlisted: tt.unlisted, PublishAt: tt.publishAt}
+			if got := project.IsPublished(now); got != tt.wantPublished {
+				t.Errorf("Project.IsPublished() = %v, want %v", got, tt.wantPublished)
+			}
+			if got := project.IsListed(now); got != tt.wantListed {
+				t.Errorf("Project.IsListed() = %v, want %v", got, tt.wantListed)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package assets
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/rotisserie/eris"
+)
+
+// Drift is the difference between the files of the vault and the objects of
+// the bucket.
+type Drift struct {
+	// Missing are in the vault but not in the bucket.
+	Missing []string
+	// Changed are in both but differ.
+	Changed []string
+	// Extra are in the bucket but not in the vault.
+	Extra []string
+	// InSync is the number of files the bucket holds as they are.
+	InSync int
+}
+
+// RemoteETags lists the ETags of the objects in the bucket under prefix,
+// keyed by object key.
+func RemoteETags(
+	ctx context.Context,
+	client Tigris,
+	bucket string,
+	prefix string,
+) (map[string]string, error) {
+	etags := map[string]string{}
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: &bucket,
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, eris.Wrapf(err, "failed to list bucket %s", bucket)
+		}
+		for _, object := range page.Contents {
+			etags[aws.ToString(object.Key)] = trimETag(object.ETag)
+		}
+	}
+
+	return etags, nil
+}
+
+// Reconcile compares the files to upload with the ETags of the bucket's
+// objects and returns only the files that are missing or differ, along with
+// the drift between the two.
+func (u *Uploader) Reconcile(
+	files []Upload,
+	remote map[string]string,
+) ([]Upload, Drift) {
+	var (
+		drift   Drift
+		pending []Upload
+		local   = make(map[string]bool, len(files))
+	)
+	for _, file := range files {
+		local[file.Path] = true
+		etag, ok := remote[file.Path]
+		switch {
+		case !ok:
+			drift.Missing = append(drift.Missing, file.Path)
+		case etag != u.ETag(file.Data):
+			drift.Changed = append(drift.Changed, file.Path)
+		default:
+			drift.InSync++
+
+			continue
+		}
+		pending = append(pending, file)
+	}
+	for key := range remote {
+		if !local[key] {
+			drift.Extra = append(drift.Extra, key)
+		}
+	}
+	slices.Sort(drift.Extra)
+
+	return pending, drift
+}
+
+// Report writes a human readable summary of the drift to w.
+func (d Drift) Report(w io.Writer) {
+	fmt.Fprintf(
+		w,
+		"bucket drift: %d missing, %d changed, %d only in bucket, %d in sync\n",
+		len(d.Missing),
+		len(d.Changed),
+		len(d.Extra),
+		d.InSync,
+	)
+	for _, path := range d.Missing {
+		fmt.Fprintf(w, "  missing  %s\n", path)
+	}
+	for _, path := range d.Changed {
+		fmt.Fprintf(w, "  changed  %s\n", path)
+	}
+	for _, path := range d.Extra {
+		fmt.Fprintf(w, "  extra    %s\n", path)
+	}
+}
@@ -0,0 +1,135 @@
+package assets
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rotisserie/eris"
+	"github.com/uptrace/bun"
+)
+
+// registry holds the media metadata, image variants and manifest loaded at
+// server start, keyed by asset path, so that components can render images
+// without querying the database.
+var registry = struct {
+	sync.RWMutex
+	media    map[string]*Media
+	variants map[string][]*ImageVariant
+	manifest map[string]string
+}{
+	media:    map[string]*Media{},
+	variants: map[string][]*ImageVariant{},
+	manifest: map[string]string{},
+}
+
+// LoadMedia loads all media metadata, image variants and the manifest from
+// the database into the registry read by MediaOf, ImageVariantsOf and
+// AssetURL.
+func LoadMedia(ctx context.Context, db *bun.DB) error {
+	var (
+		media    []*Media
+		variants []*ImageVariant
+		entries  []*ManifestEntry
+	)
+	err := db.NewSelect().
+		Model(&media).
+		Scan(ctx)
+	if err != nil {
+		return eris.Wrap(err, "failed to load media")
+	}
+	err = db.NewSelect().
+		Model(&variants).
+		Order("path", "width").
+		Scan(ctx)
+	if err != nil {
+		return eris.Wrap(err, "failed to load image variants")
+	}
+	err = db.NewSelect().
+		Model(&entries).
+		Scan(ctx)
+	if err != nil {
+		return eris.Wrap(err, "failed to load manifest")
+	}
+	manifest := make(map[string]string, len(entries))
+	for _, e := range entries {
+		manifest[e.Path] = e.Key
+	}
+	byPath := make(map[string]*Media, len(media))
+	for _, m := range media {
+		byPath[m.Path] = m
+	}
+	variantsByPath := make(map[string][]*ImageVariant)
+	for _, v := range variants {
+		variantsByPath[v.Path] = append(variantsByPath[v.Path], v)
+	}
+	if LocalAssets() {
+		// The variants and content-addressed copies only exist in the
+		// bucket; the vault holds the originals under their own paths.
+		variantsByPath = map[string][]*ImageVariant{}
+		manifest = map[string]string{}
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	registry.media = byPath
+	registry.variants = variantsByPath
+	registry.manifest = manifest
+
+	return nil
+}
+
+// AssetURL returns the URL of the asset at path, pointing at its
+// content-addressed key when the manifest knows it.
+func AssetURL(path string) string {
+	registry.RLock()
+	key, ok := registry.manifest[path]
+	registry.RUnlock()
+	if ok {
+		return BucketPath(key)
+	}
+
+	return BucketPath(path)
+}
+
+// MediaOf returns the metadata of the asset at path, or nil if unknown.
+func MediaOf(path string) *Media {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	return registry.media[path]
+}
+
+// ImageVariantsOf returns the known variants of the image at path, including
+// the original, ordered by width.
+func ImageVariantsOf(path string) []*ImageVariant {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	return registry.variants[path]
+}
+
+// Original returns the variant describing the original image, if known.
+func Original(variants []*ImageVariant) *ImageVariant {
+	for _, v := range variants {
+		if v.Key == v.Path {
+			return v
+		}
+	}
+
+	return nil
+}
+
+// SrcSet returns the srcset attribute listing the variants of the given
+// content type.
+func SrcSet(variants []*ImageVariant, contentType string) string {
+	var candidates []string
+	for _, v := range variants {
+		if v.ContentType != contentType {
+			continue
+		}
+		candidates = append(candidates, AssetURL(v.Key)+" "+strconv.Itoa(v.Width)+"w")
+	}
+
+	return strings.Join(candidates, ", ")
+}
@@ -0,0 +1,174 @@
+package assets
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+
+	mathjax "github.com/litao91/goldmark-mathjax"
+	"github.com/rotisserie/eris"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+	"go.abhg.dev/goldmark/mermaid"
+)
+
+// Renderer renders TeX and mermaid diagrams to markup while content is built,
+// so pages do not need MathJax or mermaid in the browser.
+type Renderer interface {
+	mermaid.Compiler
+	// RenderMath renders a TeX formula to MathML or SVG markup.
+	RenderMath(ctx context.Context, tex string, display bool) ([]byte, error)
+}
+
+// CLIRenderer is a Renderer backed by the mmdc (mermaid-cli) and tex2svg
+// (mathjax-node-cli) executables.
+type CLIRenderer struct {
+	mermaid.CLICompiler
+
+	// TeXPath is the path to tex2svg, looked up on $PATH when empty.
+	TeXPath string
+}
+
+// NewCLIRenderer creates a CLIRenderer using the executables on $PATH.
+func NewCLIRenderer() *CLIRenderer {
+	return &CLIRenderer{
+		CLICompiler: mermaid.CLICompiler{Theme: "dark"},
+		TeXPath:     "tex2svg",
+	}
+}
+
+// RenderMath renders a TeX formula to SVG with tex2svg.
+func (r *CLIRenderer) RenderMath(
+	ctx context.Context,
+	tex string,
+	display bool,
+) ([]byte, error) {
+	path := r.TeXPath
+	if path == "" {
+		path = "tex2svg"
+	}
+	args := []string{}
+	if !display {
+		args = append(args, "--inline")
+	}
+	args = append(args, tex)
+	out, err := exec.CommandContext(ctx, path, args...).Output()
+	if err != nil {
+		return nil, eris.Wrapf(err, "failed to render math: %s", tex)
+	}
+
+	return bytes.TrimSpace(out), nil
+}
+
+// MDOption configures the markdown pipeline created by NewMD.
+type MDOption func(*mdConfig)
+
+type mdConfig struct {
+	renderer Renderer
+	media    func(path string) *Media
+}
+
+// WithRenderer renders math and mermaid diagrams with r instead of leaving
+// them for MathJax and mermaid in the browser.
+func WithRenderer(r Renderer) MDOption {
+	return func(c *mdConfig) {
+		c.renderer = r
+	}
+}
+
+// WithMedia looks up the metadata of embedded videos, such as their poster
+// frames and dimensions, with lookup.
+func WithMedia(lookup func(path string) *Media) MDOption {
+	return func(c *mdConfig) {
+		c.media = lookup
+	}
+}
+
+// serverMath is a goldmark extension that parses MathJax delimited TeX and
+// renders it with a Renderer.
+type serverMath struct{ r Renderer }
+
+// Extend implements goldmark.Extender.
+func (e *serverMath) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithBlockParsers(
+		util.Prioritized(mathjax.NewMathJaxBlockParser(), 701),
+	))
+	m.Parser().AddOptions(parser.WithInlineParsers(
+		util.Prioritized(mathjax.NewInlineMathParser(), 501),
+	))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(e, 501),
+	))
+}
+
+// RegisterFuncs implements renderer.NodeRenderer.
+func (e *serverMath) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(mathjax.KindMathBlock, e.renderBlock)
+	reg.Register(mathjax.KindInlineMath, e.renderInline)
+}
+
+func (e *serverMath) renderBlock(
+	w util.BufWriter,
+	source []byte,
+	node ast.Node,
+	entering bool,
+) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	var tex bytes.Buffer
+	lines := node.Lines()
+	for i := range lines.Len() {
+		line := lines.At(i)
+		tex.Write(line.Value(source))
+	}
+	out, err := e.r.RenderMath(context.Background(), tex.String(), true)
+	if err != nil {
+		return ast.WalkStop, err
+	}
+	_, _ = w.WriteString(`<div class="tex display">`)
+	_, _ = w.Write(out)
+	_, _ = w.WriteString("</div>\n")
+
+	return ast.WalkSkipChildren, nil
+}
+
+func (e *serverMath) renderInline(
+	w util.BufWriter,
+	source []byte,
+	node ast.Node,
+	entering bool,
+) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	var tex bytes.Buffer
+	for c := node.FirstChild(); c != nil; c = c.NextSibling() {
+		text, ok := c.(*ast.Text)
+		if !ok {
+			continue
+		}
+		value := text.Segment.Value(source)
+		if !bytes.HasSuffix(value, []byte("\n")) {
+			tex.Write(value)
+
+			continue
+		}
+		tex.Write(value[:len(value)-1])
+		if c != node.LastChild() {
+			tex.WriteByte(' ')
+		}
+	}
+	out, err := e.r.RenderMath(context.Background(), tex.String(), false)
+	if err != nil {
+		return ast.WalkStop, err
+	}
+	_, _ = w.WriteString(`<span class="tex inline">`)
+	_, _ = w.Write(out)
+	_, _ = w.WriteString("</span>")
+
+	return ast.WalkSkipChildren, nil
+}
@@ -1,10 +1,7 @@
 package assets
 
 import (
-	"bytes"
 	"context"
-	"errors"
-	"log/slog"
 	"net/http"
 	"time"
 
@@ -28,6 +25,36 @@ type Tigris interface {
 		params *s3.PutObjectInput,
 		optFns ...func(*s3.Options),
 	) (*s3.PutObjectOutput, error)
+	CreateMultipartUpload(
+		ctx context.Context,
+		params *s3.CreateMultipartUploadInput,
+		optFns ...func(*s3.Options),
+	) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(
+		ctx context.Context,
+		params *s3.UploadPartInput,
+		optFns ...func(*s3.Options),
+	) (*s3.UploadPartOutput, error)
+	CompleteMultipartUpload(
+		ctx context.Context,
+		params *s3.CompleteMultipartUploadInput,
+		optFns ...func(*s3.Options),
+	) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(
+		ctx context.Context,
+		params *s3.AbortMultipartUploadInput,
+		optFns ...func(*s3.Options),
+	) (*s3.AbortMultipartUploadOutput, error)
+	HeadObject(
+		ctx context.Context,
+		params *s3.HeadObjectInput,
+		optFns ...func(*s3.Options),
+	) (*s3.HeadObjectOutput, error)
+	ListObjectsV2(
+		ctx context.Context,
+		params *s3.ListObjectsV2Input,
+		optFns ...func(*s3.Options),
+	) (*s3.ListObjectsV2Output, error)
 }
 
 // DefaultTigrisClient is a wrapper for the AWS S3 client.
@@ -53,9 +80,12 @@ func NewTigris(getenv func(string) string) (*DefaultTigrisClient, error) {
 			Region:       "auto",
 			BaseEndpoint: aws.String(baseURL),
 			Credentials:  credHandler,
+			// Uploader retries with its own backoff.
+			RetryMaxAttempts: 1,
+			// Requests are bounded by their contexts instead, so large
+			// uploads are not cut off by a client-wide timeout.
 			HTTPClient: &http.Client{
 				Transport: http.DefaultTransport,
-				Timeout:   10 * time.Second,
 				CheckRedirect: func(_ *http.Request, _ []*http.Request) error {
 					return http.ErrUseLastResponse
 				},
@@ -64,7 +94,7 @@ func NewTigris(getenv func(string) string) (*DefaultTigrisClient, error) {
 	}, nil
 }
 
-// UploadToS3 uploads a file to S3.
+// UploadToS3 uploads a file to S3 with the default Uploader settings.
 func UploadToS3(
 	ctx context.Context,
 	client Tigris,
@@ -72,36 +102,7 @@ func UploadToS3(
 	path string,
 	data []byte,
 ) error {
-	// Use custom content type function instead of mime.TypeByExtension
-	contentType := GetContentType(path)
-
-	timeout := defaultS3Timeout
-
-	if isVideoType(contentType) {
-		return nil
-	}
-	// Use a timeout context to prevent hanging on S3 operations
-	uploadCtx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
-
-	slog.Info("Uploading to S3", "path", path)
-	_, err := client.PutObject(uploadCtx, &s3.PutObjectInput{
-		Bucket:      &bucket,
-		Key:         aws.String(path),
-		Body:        bytes.NewReader(data),
-		ContentType: aws.String(contentType),
-	})
-	if err != nil {
-		if errors.Is(err, context.DeadlineExceeded) {
-			return eris.Wrapf(err, "S3 upload timed out: %s", path)
-		}
-
-		return eris.Wrapf(err, "failed to upload to S3: %s", path)
-	}
-
-	slog.Debug("uploaded to S3", "path", path)
-
-	return nil
+	return NewUploader(client, bucket).Upload(ctx, path, data)
 }
 
 // credHandler is the bucket for the API security.
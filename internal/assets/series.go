@@ -0,0 +1,58 @@
+package assets
+
+import "sort"
+
+// Neighbors returns the posts before and after the post with the given slug
+// in the series, either of which is nil at the ends of the series.
+func (s *Series) Neighbors(slug string) (prev, next *Post) {
+	for i, post := range s.Posts {
+		if post.Slug != slug {
+			continue
+		}
+		if i > 0 {
+			prev = s.Posts[i-1]
+		}
+		if i+1 < len(s.Posts) {
+			next = s.Posts[i+1]
+		}
+
+		break
+	}
+
+	return prev, next
+}
+
+// GroupBySeries orders posts so that the parts of a series are adjacent and
+// in series order.
+//
+// Each series takes the position of its first part in posts; posts outside of
+// a series keep their relative order.
+func GroupBySeries(posts []*Post) []*Post {
+	var (
+		grouped = make([]*Post, 0, len(posts))
+		parts   = map[string][]*Post{}
+	)
+	for _, post := range posts {
+		if post.SeriesSlug != "" {
+			parts[post.SeriesSlug] = append(parts[post.SeriesSlug], post)
+		}
+	}
+	for _, post := range posts {
+		if post.SeriesSlug == "" {
+			grouped = append(grouped, post)
+
+			continue
+		}
+		series, ok := parts[post.SeriesSlug]
+		if !ok {
+			continue
+		}
+		sort.SliceStable(series, func(i, j int) bool {
+			return series[i].SeriesOrder < series[j].SeriesOrder
+		})
+		grouped = append(grouped, series...)
+		delete(parts, post.SeriesSlug)
+	}
+
+	return grouped
+}
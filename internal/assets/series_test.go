@@ -0,0 +1,129 @@
+package assets_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/conneroisu/conneroh.com/internal/assets"
+)
+
+// part returns a post with the given slug in the series at the given order,
+// outside of any series if series is "".
+func part(slug, series string, order int) *assets.Post {
+	post := &assets.Post{}
+	post.Slug = slug
+	post.SeriesSlug = series
+	post.SeriesOrder = order
+
+	return post
+}
+
+// slugs returns the slugs of posts in order.
+func slugs(posts []*assets.Post) []string {
+	got := make([]string, 0, len(posts))
+	for _, post := range posts {
+		got = append(got, post.Slug)
+	}
+
+	return got
+}
+
+func TestGroupBySeries(t *testing.T) {
+	tests := []struct {
+		name  string
+		posts []*assets.Post
+		want  []string
+	}{
+		{name: "Empty", want: []string{}},
+		{
+			name:  "No series",
+			posts: []*assets.Post{part("a", "", 0), part("b", "", 0)},
+			want:  []string{"a", "b"},
+		},
+		{
+			name: "Series in series order",
+			posts: []*assets.Post{
+				part("s3", "s", 3),
+				part("a", "", 0),
+				part("s1", "s", 1),
+				part("s2", "s", 2),
+			},
+			want: []string{"s1", "s2", "s3", "a"},
+		},
+		{
+			name: "Series at its first part",
+			posts: []*assets.Post{
+				part("a", "", 0),
+				part("s2", "s", 2),
+				part("b", "", 0),
+				part("s1", "s", 1),
+			},
+			want: []string{"a", "s1", "s2", "b"},
+		},
+		{
+			name: "Several series",
+			posts: []*assets.Post{
+				part("t2", "t", 2),
+				part("s1", "s", 1),
+				part("t1", "t", 1),
+				part("s2", "s", 2),
+			},
+			want: []string{"t1", "t2", "s1", "s2"},
+		},
+		{
+			name:  "Equal orders keep their order",
+			posts: []*assets.Post{part("x", "s", 1), part("y", "s", 1)},
+			want:  []string{"x", "y"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := slugs(assets.GroupBySeries(tt.posts))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("GroupBySeries() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeriesNeighbors(t *testing.T) {
+	series := &assets.Series{Posts: []*assets.Post{
+		part("s1", "s", 1),
+		part("s2", "s", 2),
+		part("s3", "s", 3),
+	}}
+	single := &assets.Series{Posts: []*assets.Post{part("only", "o", 1)}}
+	tests := []struct {
+		name     string
+		series   *assets.Series
+		slug     string
+		wantPrev string
+		wantNext string
+	}{
+		{name: "First", series: series, slug: "s1", wantNext: "s2"},
+		{name: "Middle", series: series, slug: "s2", wantPrev: "s1", wantNext: "s3"},
+		{name: "Last", series: series, slug: "s3", wantPrev: "s2"},
+		{name: "Single post", series: single, slug: "only"},
+		{name: "Not in series", series: series, slug: "other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev, next := tt.series.Neighbors(tt.slug)
+			if got := slugOf(prev); got != tt.wantPrev {
+				t.Errorf("Neighbors() prev = %q, want %q", got, tt.wantPrev)
+			}
+			if got := slugOf(next); got != tt.wantNext {
+				t.Errorf("Neighbors() next = %q, want %q", got, tt.wantNext)
+			}
+		})
+	}
+}
+
+// slugOf returns the slug of post, "" if it is nil.
+func slugOf(post *assets.Post) string {
+	if post == nil {
+		return ""
+	}
+
+	return post.Slug
+}
@@ -2,15 +2,33 @@ package assets
 
 import (
 	"fmt"
+	"net/url"
 	"time"
 
 	"github.com/uptrace/bun"
 	"gopkg.in/yaml.v3"
 )
 
-// DBName returns the name/file of the database.
-func DBName() string {
-	return "file:master.db"
+const (
+	// DefaultDBPath is the SQLite database file used unless configured
+	// otherwise.
+	DefaultDBPath = "master.db"
+	// DBPathVar is the environment variable overriding the database file.
+	DBPathVar = "DATABASE_PATH"
+)
+
+// DBPath returns the database file set in the environment, or DefaultDBPath.
+func DBPath(getenv func(string) string) string {
+	if path := getenv(DBPathVar); path != "" {
+		return path
+	}
+
+	return DefaultDBPath
+}
+
+// DSN returns the data source name opening the SQLite database at path.
+func DSN(path string) string {
+	return "file:" + path
 }
 
 // CustomTime allows us to customize the YAML time parsing.
@@ -40,25 +58,35 @@ func (ct *CustomTime) UnmarshalYAML(value *yaml.Node) error {
 type (
 	// Doc is a base struct for all embeddedable structs.
 	Doc struct {
-		Title           string        `yaml:"title"`
-		Path            string        `yaml:"-"`
-		Slug            string        `yaml:"slug"`
-		Description     string        `yaml:"description"`
-		Content         string        `yaml:"-"`
-		BannerPath      string        `yaml:"banner_path"`
-		Icon            string        `yaml:"icon"`
-		CreatedAt       CustomTime    `yaml:"created_at"`
-		UpdatedAt       CustomTime    `yaml:"updated_at"`
-		EndDate         *CustomTime   `yaml:"end_date"`
-		TagSlugs        []string      `yaml:"tags"`
-		PostSlugs       []string      `yaml:"posts"`
-		ProjectSlugs    []string      `yaml:"projects"`
-		EmploymentSlugs []string      `yaml:"employments"`
-		Hash            string        `yaml:"-"`
-		Posts           []*Post       `yaml:"-"`
-		Tags            []*Tag        `yaml:"-"`
-		Projects        []*Project    `yaml:"-"`
-		Employments     []*Employment `yaml:"-"`
+		Title           string            `yaml:"title"`
+		Path            string            `yaml:"-"`
+		Slug            string            `yaml:"slug"`
+		Description     string            `yaml:"description"`
+		Content         string            `yaml:"-"`
+		BannerPath      string            `yaml:"banner_path"`
+		Icon            string            `yaml:"icon"`
+		CreatedAt       CustomTime        `yaml:"created_at"`
+		UpdatedAt       CustomTime        `yaml:"updated_at"`
+		EndDate         *CustomTime       `yaml:"end_date"`
+		PublishAt       *CustomTime       `yaml:"publish_at"`
+		Draft           bool              `yaml:"draft"`
+		Unlisted        bool              `yaml:"unlisted"`
+		SeriesTitle     string            `yaml:"series"`
+		SeriesSlug      string            `yaml:"-"`
+		SeriesOrder     int               `yaml:"series_order"`
+		TOC             []*Heading        `yaml:"-"`
+		MediaAlts       map[string]string `yaml:"-"`
+		WordCount       int               `yaml:"-"`
+		ReadingTime     int               `yaml:"-"`
+		TagSlugs        []string          `yaml:"tags"`
+		PostSlugs       []string          `yaml:"posts"`
+		ProjectSlugs    []string          `yaml:"projects"`
+		EmploymentSlugs []string          `yaml:"employments"`
+		Hash            string            `yaml:"-"`
+		Posts           []*Post           `yaml:"-"`
+		Tags            []*Tag            `yaml:"-"`
+		Projects        []*Project        `yaml:"-"`
+		Employments     []*Employment     `yaml:"-"`
 	}
 	// Cache represents an asset cache.
 	Cache struct {
@@ -66,7 +94,70 @@ type (
 
 		ID   int64  `bun:"id,pk,autoincrement"`
 		Path string `bun:"path,unique"`
-		Hash string `bun:"hashed,unique"`
+		// Hash is not unique, as distinct files may share their content.
+		Hash string `bun:"hashed"`
+	}
+	// Media is the metadata of an asset.
+	Media struct {
+		bun.BaseModel `bun:"media"`
+
+		ID int64 `bun:"id,pk,autoincrement"`
+
+		Path        string `bun:"path,unique"`
+		ContentType string `bun:"content_type"`
+		// Size is the size of the published asset in bytes.
+		Size int64 `bun:"size"`
+		// Alt is the alternative text documents describe the asset with.
+		Alt string `bun:"alt"`
+		// Width and Height are the dimensions of raster images and videos.
+		Width  int `bun:"width"`
+		Height int `bun:"height"`
+		// DominantColor is the most common color as a CSS hex color.
+		DominantColor string `bun:"dominant_color"`
+		// Placeholder is a tiny, blurry rendition as a data URI.
+		Placeholder string `bun:"placeholder"`
+		// Poster is the asset path of the poster frame of a video.
+		Poster string `bun:"poster"`
+
+		// M2M relationships
+		Posts    []*Post    `bun:"m2m:media_to_posts,join:Media=Post"`
+		Projects []*Project `bun:"m2m:media_to_projects,join:Media=Project"`
+	}
+	// ManifestEntry maps the logical path of a published file to the
+	// content-addressed key it is stored under in the bucket.
+	ManifestEntry struct {
+		bun.BaseModel `bun:"manifest"`
+
+		ID int64 `bun:"id,pk,autoincrement"`
+
+		Path string `bun:"path,unique"`
+		Key  string `bun:"key"`
+	}
+	// ImageVariant is a stored rendition of an image asset.
+	ImageVariant struct {
+		bun.BaseModel `bun:"image_variants"`
+
+		ID int64 `bun:"id,pk,autoincrement"`
+
+		// Path is the asset path of the original image.
+		Path string `bun:"path"`
+		// Key is the asset path of the variant.
+		Key         string `bun:"key,unique"`
+		ContentType string `bun:"content_type"`
+		Width       int    `bun:"width"`
+		Height      int    `bun:"height"`
+	}
+	// Revision is a git commit that changed a document's markdown source.
+	Revision struct {
+		bun.BaseModel `bun:"revisions"`
+
+		ID int64 `bun:"id,pk,autoincrement"`
+
+		DocPath string    `bun:"doc_path,unique:doc_path_hash"`
+		Hash    string    `bun:"hash,unique:doc_path_hash"`
+		Date    time.Time `bun:"date"`
+		Summary string    `bun:"summary"`
+		Source  string    `bun:"source"`
 	}
 	// Post is a post with all its projects and tags.
 	Post struct {
@@ -74,23 +165,54 @@ type (
 
 		ID int64 `bun:"id,pk,autoincrement" `
 
-		Title       string     `bun:"title"`
-		Slug        string     `bun:"slug,unique"`
-		Description string     `bun:"description"`
-		Content     string     `bun:"content"`
-		BannerPath  string     `bun:"banner_path"`
-		CreatedAt   CustomTime `bun:"created_at"`
+		Title       string      `bun:"title"`
+		Slug        string      `bun:"slug,unique"`
+		Path        string      `bun:"path"`
+		Description string      `bun:"description"`
+		Content     string      `bun:"content"`
+		BannerPath  string      `bun:"banner_path"`
+		CreatedAt   CustomTime  `bun:"created_at"`
+		UpdatedAt   CustomTime  `bun:"updated_at"`
+		PublishAt   *CustomTime `bun:"publish_at"`
+		Draft       bool        `bun:"draft"`
+		Unlisted    bool        `bun:"unlisted"`
+		TOC         []*Heading  `bun:"toc"`
+		WordCount   int         `bun:"word_count"`
+		ReadingTime int         `bun:"reading_time"`
+		SeriesSlug  string      `bun:"series_slug"`
+		SeriesOrder int         `bun:"series_order"`
 
 		TagSlugs        []string
 		PostSlugs       []string
 		ProjectSlugs    []string
 		EmploymentSlugs []string
 
+		// MediaAlts maps the assets the post uses to their alt text.
+		MediaAlts map[string]string `bun:"-"`
+
 		// M2M relationships
 		Tags        []*Tag        `bun:"m2m:post_to_tags,join:Post=Tag"`
 		Posts       []*Post       `bun:"m2m:post_to_posts,join:SourcePost=TargetPost"`
 		Projects    []*Project    `bun:"m2m:post_to_projects,join:Post=Project"`
 		Employments []*Employment `bun:"m2m:employment_to_posts,join:Post=Employment"`
+		Media       []*Media      `bun:"m2m:media_to_posts,join:Post=Media"`
+
+		// Revisions is the git history of the post's markdown source.
+		Revisions []*Revision `bun:"rel:has-many,join:path=doc_path"`
+		// Series is the multi-part series the post belongs to, if any.
+		Series *Series `bun:"rel:belongs-to,join:series_slug=slug"`
+	}
+
+	// Series is an ordered collection of posts that form a multi-part write-up.
+	Series struct {
+		bun.BaseModel `bun:"series"`
+
+		ID int64 `bun:"id,pk,autoincrement"`
+
+		Title string `bun:"title"`
+		Slug  string `bun:"slug,unique"`
+
+		Posts []*Post `bun:"rel:has-many,join:slug=series_slug"`
 	}
 
 	// Project is a project with all its posts and tags.
@@ -99,23 +221,35 @@ type (
 
 		ID int64 `bun:"id,pk,autoincrement" yaml:"-"`
 
-		Title       string     `bun:"title"`
-		Slug        string     `bun:"slug,unique"`
-		Description string     `bun:"description"`
-		Content     string     `bun:"content"`
-		BannerPath  string     `bun:"banner_path"`
-		CreatedAt   CustomTime `bun:"created_at"`
+		Title       string      `bun:"title"`
+		Slug        string      `bun:"slug,unique"`
+		Path        string      `bun:"path"`
+		Description string      `bun:"description"`
+		Content     string      `bun:"content"`
+		BannerPath  string      `bun:"banner_path"`
+		CreatedAt   CustomTime  `bun:"created_at"`
+		UpdatedAt   CustomTime  `bun:"updated_at"`
+		PublishAt   *CustomTime `bun:"publish_at"`
+		Draft       bool        `bun:"draft"`
+		Unlisted    bool        `bun:"unlisted"`
+		TOC         []*Heading  `bun:"toc"`
+		WordCount   int         `bun:"word_count"`
+		ReadingTime int         `bun:"reading_time"`
 
 		TagSlugs        []string `bun:"tag_slugs"`
 		PostSlugs       []string `bun:"post_slugs"`
 		ProjectSlugs    []string `bun:"project_slugs"`
 		EmploymentSlugs []string `bun:"employment_slugs"`
 
+		// MediaAlts maps the assets the project uses to their alt text.
+		MediaAlts map[string]string `bun:"-"`
+
 		// M2M relationships
 		Tags        []*Tag        `bun:"m2m:project_to_tags,join:Project=Tag"`
 		Posts       []*Post       `bun:"m2m:post_to_projects,join:Project=Post"`
 		Projects    []*Project    `bun:"m2m:project_to_projects,join:SourceProject=TargetProject"`
 		Employments []*Employment `bun:"m2m:employment_to_projects,join:Project=Employment"`
+		Media       []*Media      `bun:"m2m:media_to_projects,join:Project=Media"`
 	}
 
 	// Tag is a tag with all its posts and projects.
@@ -131,6 +265,7 @@ type (
 		BannerPath  string     `bun:"banner_path"`
 		Icon        string     `bun:"icon"`
 		CreatedAt   CustomTime `bun:"created_at"`
+		UpdatedAt   CustomTime `bun:"updated_at"`
 
 		TagSlugs        []string `bun:"tag_slugs"`
 		PostSlugs       []string `bun:"post_slugs"`
@@ -156,6 +291,7 @@ type (
 		Content     string      `bun:"content"`
 		BannerPath  string      `bun:"banner_path"`
 		CreatedAt   CustomTime  `bun:"created_at"`
+		UpdatedAt   CustomTime  `bun:"updated_at"`
 		EndDate     *CustomTime `bun:"end_date"`
 
 		TagSlugs        []string `bun:"tag_slugs"`
@@ -190,6 +326,28 @@ type (
 		Project   *Project `bun:"rel:belongs-to,join:project_id=id"`
 	}
 
+	// MediaToPost represents a many-to-many relationship between media and the
+	// posts that use them.
+	MediaToPost struct {
+		bun.BaseModel `bun:"media_to_posts"`
+
+		MediaID int64  `bun:"media_id,pk"`
+		Media   *Media `bun:"rel:belongs-to,join:media_id=id"`
+		PostID  int64  `bun:"post_id,pk"`
+		Post    *Post  `bun:"rel:belongs-to,join:post_id=id"`
+	}
+
+	// MediaToProject represents a many-to-many relationship between media and
+	// the projects that use them.
+	MediaToProject struct {
+		bun.BaseModel `bun:"media_to_projects"`
+
+		MediaID   int64    `bun:"media_id,pk"`
+		Media     *Media   `bun:"rel:belongs-to,join:media_id=id"`
+		ProjectID int64    `bun:"project_id,pk"`
+		Project   *Project `bun:"rel:belongs-to,join:project_id=id"`
+	}
+
 	// ProjectToTag represents a many-to-many relationship between projects and tags.
 	ProjectToTag struct {
 		bun.BaseModel `bun:"project_to_tags"`
@@ -281,6 +439,19 @@ func (emb *Post) PagePath() string {
 	return "/post/" + emb.Slug
 }
 
+// DiffPath returns the path to the diff of the post between two revisions.
+func (emb *Post) DiffPath(from, to string) string {
+	return "/diff/post/" + emb.Slug + "?" + url.Values{
+		"from": {from},
+		"to":   {to},
+	}.Encode()
+}
+
+// PagePath returns the path to the series page.
+func (emb *Series) PagePath() string {
+	return "/series/" + emb.Slug
+}
+
 // PagePath returns the path to the project page.
 func (emb *Project) PagePath() string {
 	return "/project/" + emb.Slug
@@ -296,6 +467,11 @@ func (emb *Employment) PagePath() string {
 	return "/employment/" + emb.Slug
 }
 
+// PagePath returns the path to the media page.
+func (emb *Media) PagePath() string {
+	return "/media/" + emb.Path
+}
+
 func (emb *Post) String() string {
 	return fmt.Sprintf("Post: %s %s %s %d", emb.Title, emb.Slug, emb.Description, emb.ID)
 }
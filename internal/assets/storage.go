@@ -0,0 +1,443 @@
+package assets
+
+import (
+	"context"
+	//nolint:gosec
+	"crypto/md5"
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/rotisserie/eris"
+	"github.com/spf13/afero"
+)
+
+const (
+	// StorageURLVar is the environment variable selecting where assets are
+	// uploaded: s3://bucket, file://dir or mem://.
+	StorageURLVar = "STORAGE_URL"
+	// bucketNameVar is the environment variable naming the S3 bucket when
+	// no storage URL is set.
+	bucketNameVar = "BUCKET_NAME"
+
+	// metaLoc is the directory of a LocalStore holding object metadata.
+	metaLoc = ".meta/"
+	// defaultMaxKeys is how many keys ListObjectsV2 returns per page.
+	defaultMaxKeys = 1000
+)
+
+// OpenStorage opens the storage backend named by the STORAGE_URL environment
+// variable and returns it along with its bucket name.
+//
+//   - s3://bucket uploads to an S3-compatible bucket, Tigris by default.
+//   - file://dir stores objects as files under dir.
+//   - mem:// keeps objects in memory, for dry runs and tests.
+//
+// Without STORAGE_URL, the S3 bucket named by BUCKET_NAME is used.
+func OpenStorage(getenv func(string) string) (Tigris, string, error) {
+	u, err := storageURL(getenv)
+	if err != nil {
+		return nil, "", err
+	}
+	switch u.Scheme {
+	case "s3":
+		client, err := NewTigris(getenv)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return client, u.Host, nil
+	case "file":
+		dir := u.Host + u.Path
+		err = os.MkdirAll(dir, 0o755)
+		if err != nil {
+			return nil, "", eris.Wrapf(err, "failed to create storage directory: %s", dir)
+		}
+
+		return NewFileStore(dir), path.Base(dir), nil
+	default:
+		return NewMemStore(), "mem", nil
+	}
+}
+
+// StateDBPath returns the database that runs publishing to the storage named
+// by STORAGE_URL record what they published in: the site's database at
+// dbPath for S3, a database next to the directory of a file store, and "" for
+// mem://, whose runs need a throwaway one. Keeping the state of local storage
+// apart stops its runs from marking content as published to the bucket.
+func StateDBPath(getenv func(string) string, dbPath string) (string, error) {
+	u, err := storageURL(getenv)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "s3":
+		return dbPath, nil
+	case "file":
+		return filepath.Clean(u.Host+u.Path) + ".db", nil
+	default:
+		return "", nil
+	}
+}
+
+// storageURL parses and validates the storage URL set in the environment.
+func storageURL(getenv func(string) string) (*url.URL, error) {
+	raw := getenv(StorageURLVar)
+	if raw == "" {
+		bucket := getenv(bucketNameVar)
+		if bucket == "" {
+			return nil, eris.Wrapf(
+				ErrValueMissing,
+				"neither %s nor %s is set",
+				StorageURLVar,
+				bucketNameVar,
+			)
+		}
+		raw = "s3://" + bucket
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, eris.Wrapf(err, "invalid storage URL: %s", raw)
+	}
+	switch u.Scheme {
+	case "s3":
+		if u.Host == "" {
+			return nil, eris.Wrapf(ErrValueMissing, "storage URL has no bucket: %s", raw)
+		}
+	case "file":
+		if u.Host+u.Path == "" {
+			return nil, eris.Wrapf(ErrValueMissing, "storage URL has no directory: %s", raw)
+		}
+	case "mem":
+	default:
+		return nil, eris.Wrapf(ErrValueInvalid, "unsupported storage URL scheme: %s", raw)
+	}
+
+	return u, nil
+}
+
+// LocalStore is a bucket kept in a filesystem, implementing the subset of S3
+// the Uploader uses so content can be synced without cloud credentials.
+//
+// Objects are stored under their keys; their ETags and headers are kept in
+// metadata files under .meta/. The bucket names of requests are ignored.
+type LocalStore struct {
+	fs afero.Fs
+
+	mu      sync.Mutex
+	uploads map[string]*multipartUpload
+	next    int
+}
+
+// objectMeta is the metadata of an object in a LocalStore.
+type objectMeta struct {
+	ETag         string `json:"etag"`
+	ContentType  string `json:"content_type,omitempty"`
+	CacheControl string `json:"cache_control,omitempty"`
+}
+
+// multipartUpload is an upload in progress, kept in memory until it
+// completes.
+type multipartUpload struct {
+	key   string
+	meta  objectMeta
+	parts map[int32][]byte
+}
+
+// NewFileStore creates a LocalStore keeping objects under dir.
+func NewFileStore(dir string) *LocalStore {
+	return newLocalStore(afero.NewBasePathFs(afero.NewOsFs(), dir))
+}
+
+// NewMemStore creates a LocalStore keeping objects in memory.
+func NewMemStore() *LocalStore {
+	return newLocalStore(afero.NewMemMapFs())
+}
+
+func newLocalStore(fs afero.Fs) *LocalStore {
+	return &LocalStore{
+		fs:      fs,
+		uploads: map[string]*multipartUpload{},
+	}
+}
+
+// Object returns the content of the object at key.
+func (s *LocalStore) Object(key string) ([]byte, error) {
+	return afero.ReadFile(s.fs, "/"+key)
+}
+
+// PutObject stores an object.
+func (s *LocalStore) PutObject(
+	_ context.Context,
+	in *s3.PutObjectInput,
+	_ ...func(*s3.Options),
+) (*s3.PutObjectOutput, error) {
+	data, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, eris.Wrap(err, "failed to read object")
+	}
+	meta := objectMeta{
+		ETag:         ComputeHash(data),
+		ContentType:  aws.ToString(in.ContentType),
+		CacheControl: aws.ToString(in.CacheControl),
+	}
+	err = s.write(aws.ToString(in.Key), data, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3.PutObjectOutput{ETag: quoteETag(meta.ETag)}, nil
+}
+
+// CreateMultipartUpload starts a multipart upload.
+func (s *LocalStore) CreateMultipartUpload(
+	_ context.Context,
+	in *s3.CreateMultipartUploadInput,
+	_ ...func(*s3.Options),
+) (*s3.CreateMultipartUploadOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next++
+	id := strconv.Itoa(s.next)
+	s.uploads[id] = &multipartUpload{
+		key: aws.ToString(in.Key),
+		meta: objectMeta{
+			ContentType:  aws.ToString(in.ContentType),
+			CacheControl: aws.ToString(in.CacheControl),
+		},
+		parts: map[int32][]byte{},
+	}
+
+	return &s3.CreateMultipartUploadOutput{
+		Bucket:   in.Bucket,
+		Key:      in.Key,
+		UploadId: aws.String(id),
+	}, nil
+}
+
+// UploadPart stores a part of a multipart upload.
+func (s *LocalStore) UploadPart(
+	_ context.Context,
+	in *s3.UploadPartInput,
+	_ ...func(*s3.Options),
+) (*s3.UploadPartOutput, error) {
+	data, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, eris.Wrap(err, "failed to read part")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	upload, err := s.upload(in.UploadId)
+	if err != nil {
+		return nil, err
+	}
+	upload.parts[aws.ToInt32(in.PartNumber)] = data
+
+	return &s3.UploadPartOutput{ETag: quoteETag(ComputeHash(data))}, nil
+}
+
+// CompleteMultipartUpload joins the parts of a multipart upload into an
+// object whose ETag is computed the way S3 does.
+func (s *LocalStore) CompleteMultipartUpload(
+	_ context.Context,
+	in *s3.CompleteMultipartUploadInput,
+	_ ...func(*s3.Options),
+) (*s3.CompleteMultipartUploadOutput, error) {
+	s.mu.Lock()
+	upload, err := s.upload(in.UploadId)
+	if err == nil {
+		delete(s.uploads, aws.ToString(in.UploadId))
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	var data, sums []byte
+	for _, part := range in.MultipartUpload.Parts {
+		chunk, ok := upload.parts[aws.ToInt32(part.PartNumber)]
+		if !ok {
+			return nil, eris.Errorf("missing part %d of %s", aws.ToInt32(part.PartNumber), upload.key)
+		}
+		data = append(data, chunk...)
+		//nolint:gosec
+		sum := md5.Sum(chunk)
+		sums = append(sums, sum[:]...)
+	}
+	upload.meta.ETag = ComputeHash(sums) + "-" + strconv.Itoa(len(in.MultipartUpload.Parts))
+	err = s.write(upload.key, data, upload.meta)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3.CompleteMultipartUploadOutput{
+		Bucket: in.Bucket,
+		Key:    in.Key,
+		ETag:   quoteETag(upload.meta.ETag),
+	}, nil
+}
+
+// AbortMultipartUpload discards the parts of a multipart upload.
+func (s *LocalStore) AbortMultipartUpload(
+	_ context.Context,
+	in *s3.AbortMultipartUploadInput,
+	_ ...func(*s3.Options),
+) (*s3.AbortMultipartUploadOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.uploads, aws.ToString(in.UploadId))
+
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+// HeadObject returns the metadata of an object, failing with
+// types.NotFound if there is none.
+func (s *LocalStore) HeadObject(
+	_ context.Context,
+	in *s3.HeadObjectInput,
+	_ ...func(*s3.Options),
+) (*s3.HeadObjectOutput, error) {
+	key := aws.ToString(in.Key)
+	meta, err := s.meta(key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := s.fs.Stat("/" + key)
+	if err != nil {
+		return nil, eris.Wrapf(err, "failed to stat object: %s", key)
+	}
+
+	return &s3.HeadObjectOutput{
+		ETag:          quoteETag(meta.ETag),
+		ContentType:   aws.String(meta.ContentType),
+		CacheControl:  aws.String(meta.CacheControl),
+		ContentLength: aws.Int64(info.Size()),
+		LastModified:  aws.Time(info.ModTime()),
+	}, nil
+}
+
+// ListObjectsV2 lists the objects under a prefix in key order, continuing
+// after the key named by the continuation token.
+func (s *LocalStore) ListObjectsV2(
+	_ context.Context,
+	in *s3.ListObjectsV2Input,
+	_ ...func(*s3.Options),
+) (*s3.ListObjectsV2Output, error) {
+	var keys []string
+	err := afero.Walk(s.fs, "/", func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		key := strings.TrimPrefix(p, "/")
+		if info.IsDir() {
+			if key+"/" == metaLoc {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+		if strings.HasPrefix(key, aws.ToString(in.Prefix)) &&
+			key > aws.ToString(in.ContinuationToken) {
+			keys = append(keys, key)
+		}
+
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, eris.Wrap(err, "failed to list objects")
+	}
+	slices.Sort(keys)
+	limit := int(aws.ToInt32(in.MaxKeys))
+	if limit <= 0 {
+		limit = defaultMaxKeys
+	}
+	truncated := len(keys) > limit
+	keys = keys[:min(limit, len(keys))]
+	out := &s3.ListObjectsV2Output{
+		Name:        in.Bucket,
+		Prefix:      in.Prefix,
+		KeyCount:    aws.Int32(int32(len(keys))), //nolint:gosec
+		IsTruncated: aws.Bool(truncated),
+	}
+	for _, key := range keys {
+		meta, err := s.meta(key)
+		if err != nil {
+			return nil, err
+		}
+		out.Contents = append(out.Contents, types.Object{
+			Key:  aws.String(key),
+			ETag: quoteETag(meta.ETag),
+		})
+	}
+	if truncated {
+		out.NextContinuationToken = aws.String(keys[len(keys)-1])
+	}
+
+	return out, nil
+}
+
+// upload returns the multipart upload with the given ID. The caller must
+// hold s.mu.
+func (s *LocalStore) upload(id *string) (*multipartUpload, error) {
+	upload, ok := s.uploads[aws.ToString(id)]
+	if !ok {
+		return nil, &types.NoSuchUpload{Message: aws.String("no such upload: " + aws.ToString(id))}
+	}
+
+	return upload, nil
+}
+
+// write stores an object and its metadata.
+func (s *LocalStore) write(key string, data []byte, meta objectMeta) error {
+	encoded, err := json.Marshal(meta)
+	if err != nil {
+		return eris.Wrap(err, "failed to encode object metadata")
+	}
+	for p, content := range map[string][]byte{
+		"/" + key:                     data,
+		"/" + metaLoc + key + ".json": encoded,
+	} {
+		err = s.fs.MkdirAll(path.Dir(p), 0o755)
+		if err != nil {
+			return eris.Wrapf(err, "failed to create directory for %s", key)
+		}
+		err = afero.WriteFile(s.fs, p, content, 0o644)
+		if err != nil {
+			return eris.Wrapf(err, "failed to write object: %s", key)
+		}
+	}
+
+	return nil
+}
+
+// meta reads the metadata of the object at key.
+func (s *LocalStore) meta(key string) (objectMeta, error) {
+	var meta objectMeta
+	data, err := afero.ReadFile(s.fs, "/"+metaLoc+key+".json")
+	if os.IsNotExist(err) {
+		return meta, &types.NotFound{Message: aws.String("no such key: " + key)}
+	}
+	if err != nil {
+		return meta, eris.Wrapf(err, "failed to read object metadata: %s", key)
+	}
+	err = json.Unmarshal(data, &meta)
+	if err != nil {
+		return meta, eris.Wrapf(err, "failed to decode object metadata: %s", key)
+	}
+
+	return meta, nil
+}
+
+// quoteETag returns an ETag in the quoted form S3 responds with.
+func quoteETag(etag string) *string {
+	return aws.String(`"` + etag + `"`)
+}
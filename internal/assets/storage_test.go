@@ -0,0 +1,190 @@
+package assets_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/conneroisu/conneroh.com/internal/assets"
+)
+
+func TestLocalStore(t *testing.T) {
+	ctx := context.Background()
+	stores := map[string]*assets.LocalStore{
+		"Memory":     assets.NewMemStore(),
+		"Filesystem": assets.NewFileStore(t.TempDir()),
+	}
+	files := []assets.Upload{
+		{Path: "assets/small.txt", Data: []byte("tiny")},
+		{Path: "assets/large.bin", Data: bytes.Repeat([]byte("0123456789"), 3)},
+		{Path: "other/skipped.txt", Data: []byte("elsewhere")},
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			u := testUploader(store)
+			u.CacheControl = assets.ImmutableCacheControl
+			if err := u.UploadAll(ctx, files); err != nil {
+				t.Fatalf("UploadAll() error = %v", err)
+			}
+			for _, file := range files {
+				got, err := store.Object(file.Path)
+				if err != nil || !bytes.Equal(got, file.Data) {
+					t.Errorf("Object(%q) = %q, %v, want %q", file.Path, got, err, file.Data)
+				}
+			}
+
+			head, err := store.HeadObject(ctx, &s3.HeadObjectInput{
+				Key: aws.String("assets/large.bin"),
+			})
+			if err != nil {
+				t.Fatalf("HeadObject() error = %v", err)
+			}
+			if got := aws.ToString(head.CacheControl); got != assets.ImmutableCacheControl {
+				t.Errorf("HeadObject() cache control = %q, want %q", got, assets.ImmutableCacheControl)
+			}
+			_, err = store.HeadObject(ctx, &s3.HeadObjectInput{Key: aws.String("assets/missing")})
+			var notFound *types.NotFound
+			if !errors.As(err, &notFound) {
+				t.Errorf("HeadObject() error = %v, want NotFound", err)
+			}
+
+			remote, err := assets.RemoteETags(ctx, store, "bucket", assets.AssetsLoc)
+			if err != nil {
+				t.Fatalf("RemoteETags() error = %v", err)
+			}
+			if len(remote) != 2 {
+				t.Errorf("RemoteETags() = %v, want 2 objects", remote)
+			}
+			for _, file := range files[:2] {
+				if remote[file.Path] != u.ETag(file.Data) {
+					t.Errorf("RemoteETags()[%q] = %q, want %q", file.Path, remote[file.Path], u.ETag(file.Data))
+				}
+			}
+			pending, drift := u.Reconcile(files[:2], remote)
+			if len(pending) != 0 || drift.InSync != 2 {
+				t.Errorf("Reconcile() = %d uploads, %+v, want everything in sync", len(pending), drift)
+			}
+		})
+	}
+}
+
+func TestOpenStorage(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "bucket")
+	tests := []struct {
+		name       string
+		env        map[string]string
+		wantBucket string
+		wantErr    error
+	}{
+		{
+			name:       "Memory",
+			env:        map[string]string{assets.StorageURLVar: "mem://"},
+			wantBucket: "mem",
+		},
+		{
+			name:       "Filesystem",
+			env:        map[string]string{assets.StorageURLVar: "file://" + dir},
+			wantBucket: "bucket",
+		},
+		{
+			name:    "S3 without credentials",
+			env:     map[string]string{assets.StorageURLVar: "s3://bucket"},
+			wantErr: assets.ErrMissingCreds,
+		},
+		{
+			name: "S3 from bucket name",
+			env: map[string]string{
+				"BUCKET_NAME":           "conneroh",
+				"AWS_ACCESS_KEY_ID":     "id",
+				"AWS_SECRET_ACCESS_KEY": "secret",
+				"AWS_ENDPOINT_URL_S3":   "https://fly.storage.tigris.dev",
+			},
+			wantBucket: "conneroh",
+		},
+		{
+			name:    "Unsupported scheme",
+			env:     map[string]string{assets.StorageURLVar: "ftp://bucket"},
+			wantErr: assets.ErrValueInvalid,
+		},
+		{
+			name:    "Nothing configured",
+			env:     map[string]string{},
+			wantErr: assets.ErrValueMissing,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, bucket, err := assets.OpenStorage(func(key string) string {
+				return tt.env[key]
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("OpenStorage() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if client == nil {
+				t.Error("OpenStorage() client = nil")
+			}
+			if bucket != tt.wantBucket {
+				t.Errorf("OpenStorage() bucket = %q, want %q", bucket, tt.wantBucket)
+			}
+		})
+	}
+}
+
+func TestStateDBPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr error
+	}{
+		{
+			name: "S3 shares the site database",
+			env:  map[string]string{assets.StorageURLVar: "s3://bucket"},
+			want: "master.db",
+		},
+		{
+			name: "S3 from bucket name",
+			env:  map[string]string{"BUCKET_NAME": "conneroh"},
+			want: "master.db",
+		},
+		{
+			name: "Filesystem keeps its own",
+			env:  map[string]string{assets.StorageURLVar: "file://./tmp/bucket/"},
+			want: "tmp/bucket.db",
+		},
+		{
+			name: "Memory uses a temporary one",
+			env:  map[string]string{assets.StorageURLVar: "mem://"},
+			want: "",
+		},
+		{
+			name:    "Unsupported scheme",
+			env:     map[string]string{assets.StorageURLVar: "ftp://bucket"},
+			wantErr: assets.ErrValueInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := assets.StateDBPath(func(key string) string {
+				return tt.env[key]
+			}, "master.db")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("StateDBPath() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("StateDBPath() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package assets
+
+import (
+	"math"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"go.abhg.dev/goldmark/anchor"
+)
+
+// wordsPerMinute is the assumed reading speed used for reading time estimates.
+const wordsPerMinute = 200
+
+// Heading is an entry in a document's table of contents.
+type Heading struct {
+	ID       string     `json:"id"`
+	Text     string     `json:"text"`
+	Level    int        `json:"level"`
+	Children []*Heading `json:"children,omitempty"`
+}
+
+// Outline walks a parsed markdown document and returns its heading tree along
+// with the number of words of prose it contains.
+func Outline(root ast.Node, source []byte) ([]*Heading, int) {
+	var (
+		toc   []*Heading
+		stack []*Heading
+		// prose is the text of the document, in which words are counted
+		// once whole, as a word may span several text nodes, e.g. "three."
+		// is "three" and ".".
+		prose strings.Builder
+	)
+	_ = ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		if n.Type() == ast.TypeBlock {
+			prose.WriteByte(' ')
+		}
+		switch node := n.(type) {
+		case *anchor.Node:
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			prose.Write(node.Value(source))
+			if node.SoftLineBreak() || node.HardLineBreak() {
+				prose.WriteByte(' ')
+			}
+		case *ast.String:
+			prose.Write(node.Value)
+		case *ast.Heading:
+			id, _ := node.AttributeString("id")
+			idBytes, _ := id.([]byte)
+			heading := &Heading{
+				ID:    string(idBytes),
+				Text:  plainText(node, source),
+				Level: node.Level,
+			}
+			for len(stack) > 0 && stack[len(stack)-1].Level >= heading.Level {
+				stack = stack[:len(stack)-1]
+			}
+			if len(stack) == 0 {
+				toc = append(toc, heading)
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, heading)
+			}
+			stack = append(stack, heading)
+		}
+
+		return ast.WalkContinue, nil
+	})
+
+	return toc, len(strings.Fields(prose.String()))
+}
+
+// ReadingTime estimates the minutes needed to read the given number of words.
+func ReadingTime(words int) int {
+	return max(1, int(math.Ceil(float64(words)/wordsPerMinute)))
+}
+
+// plainText returns the plain text of an inline container, such as a heading,
+// without permalinks.
+func plainText(node ast.Node, source []byte) string {
+	var b strings.Builder
+	_ = ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := n.(type) {
+		case *anchor.Node:
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			b.Write(node.Value(source))
+			if node.SoftLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(node.Value)
+		}
+
+		return ast.WalkContinue, nil
+	})
+
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,104 @@
+package assets_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/conneroisu/conneroh.com/internal/assets"
+	"github.com/spf13/afero"
+)
+
+func TestOutline(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantTOC   string
+		wantWords int
+	}{
+		{
+			name:    "Empty document",
+			wantTOC: "null",
+		},
+		{
+			name:      "Nesting",
+			body:      "# Intro\n\n## Setup\n\n### Tools\n\n## Usage\n\n# End\n",
+			wantTOC:   `[{"id":"intro","text":"Intro","level":1,"children":[{"id":"setup","text":"Setup","level":2,"children":[{"id":"tools","text":"Tools","level":3}]},{"id":"usage","text":"Usage","level":2}]},{"id":"end","text":"End","level":1}]`,
+			wantWords: 5,
+		},
+		{
+			name:      "Skipped levels",
+			body:      "## Setup\n\n#### Tools\n\n### Usage\n\n# End\n",
+			wantTOC:   `[{"id":"setup","text":"Setup","level":2,"children":[{"id":"tools","text":"Tools","level":4},{"id":"usage","text":"Usage","level":3}]},{"id":"end","text":"End","level":1}]`,
+			wantWords: 4,
+		},
+		{
+			name:      "Duplicate headings",
+			body:      "## Setup\n\n## Setup\n",
+			wantTOC:   `[{"id":"setup","text":"Setup","level":2},{"id":"setup-1","text":"Setup","level":2}]`,
+			wantWords: 2,
+		},
+		{
+			name:      "Inline markup",
+			body:      "## The `go` *tool*\n",
+			wantTOC:   `[{"id":"the-go-tool","text":"The go tool","level":2}]`,
+			wantWords: 3,
+		},
+		{
+			name:      "Words across markup and punctuation",
+			body:      "It's *half*way done, isn't it?\n",
+			wantTOC:   "null",
+			wantWords: 5,
+		},
+		{
+			name:      "Words across lines and paragraphs",
+			body:      "one\ntwo  \nthree\n\nfour\n\n- five\n- six\n",
+			wantTOC:   "null",
+			wantWords: 6,
+		},
+		{
+			name:      "Code is not prose",
+			body:      "One two three.\n\n```go\nfunc main() { println(\"four five\") }\n```\n\n    six seven\n",
+			wantTOC:   "null",
+			wantWords: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := assets.ParseMarkdown(assets.NewMD(afero.NewMemMapFs()), assets.DirMatchItem{
+				Path:    "posts/test.md",
+				Content: "---\ntitle: Test\n---\n" + tt.body,
+			})
+			if err != nil {
+				t.Fatalf("ParseMarkdown() error = %v", err)
+			}
+			toc, err := json.Marshal(doc.TOC)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(toc) != tt.wantTOC {
+				t.Errorf("Outline() toc = %s, want %s", toc, tt.wantTOC)
+			}
+			if doc.WordCount != tt.wantWords {
+				t.Errorf("Outline() words = %d, want %d", doc.WordCount, tt.wantWords)
+			}
+		})
+	}
+}
+
+func TestReadingTime(t *testing.T) {
+	tests := []struct {
+		words int
+		want  int
+	}{
+		{words: 0, want: 1},
+		{words: 1, want: 1},
+		{words: 200, want: 1},
+		{words: 201, want: 2},
+		{words: 1000, want: 5},
+	}
+	for _, tt := range tests {
+		if got := assets.ReadingTime(tt.words); got != tt.want {
+			t.Errorf("ReadingTime(%d) = %d, want %d", tt.words, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,394 @@
+package assets
+
+import (
+	"bytes"
+	"context"
+	//nolint:gosec
+	"crypto/md5"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/rotisserie/eris"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// DefaultMultipartThreshold is the size above which objects are uploaded
+	// in parts.
+	DefaultMultipartThreshold = 16 << 20
+	// DefaultPartSize is the size of every part but the last of a multipart
+	// upload. S3 requires at least 5 MiB.
+	DefaultPartSize = 8 << 20
+	// DefaultMaxAttempts is how many times a request is tried before giving up.
+	DefaultMaxAttempts = 5
+	// DefaultConcurrency is how many files are uploaded at once.
+	DefaultConcurrency = 8
+	// DefaultMinThroughput is the slowest upload speed, in bytes per second,
+	// requests are given time for, so a 16 MiB part gets about a minute on
+	// top of the attempt timeout.
+	DefaultMinThroughput = 256 << 10
+
+	// ImmutableCacheControl lets browsers and CDNs cache content-addressed
+	// objects forever, as their keys change whenever their content does.
+	ImmutableCacheControl = "public, max-age=31536000, immutable"
+)
+
+// retryables classifies the errors worth retrying: throttling, 5xx responses
+// and connection failures.
+var retryables = retry.IsErrorRetryables(retry.DefaultRetryables)
+
+// Upload is a file to upload to the bucket.
+type Upload struct {
+	Path string
+	Data []byte
+}
+
+// ContentAddress moves files under the assets prefix to their
+// content-addressed keys and returns the manifest entries mapping their
+// logical paths to those keys.
+func ContentAddress(files []Upload) ([]Upload, []*ManifestEntry) {
+	addressed := make([]Upload, 0, len(files))
+	entries := make([]*ManifestEntry, 0, len(files))
+	for _, file := range files {
+		path, ok := strings.CutPrefix(file.Path, AssetsLoc)
+		if !ok {
+			addressed = append(addressed, file)
+
+			continue
+		}
+		key := HashedKey(path, file.Data)
+		addressed = append(addressed, Upload{Path: AssetsLoc + key, Data: file.Data})
+		entries = append(entries, &ManifestEntry{Path: path, Key: key})
+	}
+
+	return addressed, entries
+}
+
+// Uploader uploads files to a bucket, in parts above a size threshold,
+// retrying retryable failures with exponential backoff.
+//
+// A failed part of a multipart upload is retried on its own, so a flaky link
+// does not restart a large upload from the beginning.
+type Uploader struct {
+	Client Tigris
+	Bucket string
+
+	// MultipartThreshold is the size above which files are uploaded in parts.
+	MultipartThreshold int
+	// PartSize is the size of the parts of multipart uploads.
+	PartSize int
+	// MaxAttempts is how many times a request is tried before giving up.
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry, doubled on every
+	// following one up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// AttemptTimeout bounds every single request, extended by the time
+	// sending its body takes at MinThroughput.
+	AttemptTimeout time.Duration
+	// MinThroughput is the slowest upload speed, in bytes per second,
+	// requests are given time for. Zero does not extend AttemptTimeout.
+	MinThroughput int
+	// Concurrency is how many files UploadAll uploads at once.
+	Concurrency int
+	// SkipUnchanged checks the ETag of the remote object first and skips
+	// uploading files the bucket already holds.
+	SkipUnchanged bool
+	// CacheControl is the Cache-Control header objects are served with.
+	CacheControl string
+}
+
+// NewUploader creates an Uploader with the default settings.
+func NewUploader(client Tigris, bucket string) *Uploader {
+	return &Uploader{
+		Client:             client,
+		Bucket:             bucket,
+		MultipartThreshold: DefaultMultipartThreshold,
+		PartSize:           DefaultPartSize,
+		MaxAttempts:        DefaultMaxAttempts,
+		BaseDelay:          500 * time.Millisecond,
+		MaxDelay:           15 * time.Second,
+		AttemptTimeout:     defaultS3Timeout,
+		MinThroughput:      DefaultMinThroughput,
+		Concurrency:        DefaultConcurrency,
+	}
+}
+
+// UploadAll uploads files concurrently, stopping at the first failure.
+func (u *Uploader) UploadAll(ctx context.Context, files []Upload) error {
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(1, u.Concurrency))
+	for _, file := range files {
+		g.Go(func() error {
+			return u.Upload(ctx, file.Path, file.Data)
+		})
+	}
+
+	return g.Wait()
+}
+
+// Upload uploads a file to the bucket.
+func (u *Uploader) Upload(ctx context.Context, path string, data []byte) error {
+	if u.SkipUnchanged && u.unchanged(ctx, path, data) {
+		slog.Debug("skipping unchanged S3 object", "path", path)
+
+		return nil
+	}
+	contentType := GetContentType(path)
+	if len(data) > u.MultipartThreshold {
+		return u.uploadMultipart(ctx, path, contentType, data)
+	}
+
+	slog.Info("Uploading to S3", "path", path)
+	err := u.retry(ctx, path, len(data), func(ctx context.Context) error {
+		_, err := u.Client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:       &u.Bucket,
+			Key:          aws.String(path),
+			Body:         bytes.NewReader(data),
+			ContentType:  aws.String(contentType),
+			CacheControl: u.cacheControl(),
+		})
+
+		return err
+	})
+	if err != nil {
+		return eris.Wrapf(err, "failed to upload to S3: %s", path)
+	}
+	slog.Debug("uploaded to S3", "path", path)
+
+	return nil
+}
+
+// ETag returns the ETag S3 assigns to data when it is uploaded by u: the
+// MD5 of the object, or for multipart uploads the MD5 of the parts' MD5s
+// suffixed with the number of parts.
+func (u *Uploader) ETag(data []byte) string {
+	if len(data) <= u.MultipartThreshold {
+		return ComputeHash(data)
+	}
+	var (
+		sums  []byte
+		parts int
+	)
+	for start := 0; start < len(data); start += u.PartSize {
+		//nolint:gosec
+		sum := md5.Sum(data[start:min(start+u.PartSize, len(data))])
+		sums = append(sums, sum[:]...)
+		parts++
+	}
+
+	return ComputeHash(sums) + "-" + strconv.Itoa(parts)
+}
+
+// unchanged reports whether the bucket already holds data at path. Any
+// failure to tell, such as a missing object, counts as changed.
+func (u *Uploader) unchanged(ctx context.Context, path string, data []byte) bool {
+	var etag string
+	err := u.retry(ctx, path, 0, func(ctx context.Context) error {
+		out, err := u.Client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: &u.Bucket,
+			Key:    aws.String(path),
+		})
+		if err != nil {
+			return err
+		}
+		etag = trimETag(out.ETag)
+
+		return nil
+	})
+
+	return err == nil && etag == u.ETag(data)
+}
+
+// uploadMultipart uploads a large file in parts, aborting the upload if a
+// part keeps failing so no orphaned parts are left behind.
+func (u *Uploader) uploadMultipart(
+	ctx context.Context,
+	path string,
+	contentType string,
+	data []byte,
+) error {
+	slog.Info("Uploading to S3 in parts", "path", path, "size", len(data))
+	var uploadID *string
+	err := u.retry(ctx, path, 0, func(ctx context.Context) error {
+		created, err := u.Client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+			Bucket:       &u.Bucket,
+			Key:          aws.String(path),
+			ContentType:  aws.String(contentType),
+			CacheControl: u.cacheControl(),
+		})
+		if err != nil {
+			return err
+		}
+		uploadID = created.UploadId
+
+		return nil
+	})
+	if err != nil {
+		return eris.Wrapf(err, "failed to start multipart upload: %s", path)
+	}
+
+	parts, err := u.uploadParts(ctx, path, uploadID, data)
+	if err == nil {
+		err = u.retry(ctx, path, 0, func(ctx context.Context) error {
+			_, err := u.Client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+				Bucket:   &u.Bucket,
+				Key:      aws.String(path),
+				UploadId: uploadID,
+				MultipartUpload: &types.CompletedMultipartUpload{
+					Parts: parts,
+				},
+			})
+
+			return err
+		})
+		if err != nil {
+			err = eris.Wrapf(err, "failed to complete multipart upload: %s", path)
+		}
+	}
+	if err != nil {
+		_, abortErr := u.Client.AbortMultipartUpload(
+			context.WithoutCancel(ctx),
+			&s3.AbortMultipartUploadInput{
+				Bucket:   &u.Bucket,
+				Key:      aws.String(path),
+				UploadId: uploadID,
+			},
+		)
+		if abortErr != nil {
+			slog.Error("failed to abort multipart upload", "path", path, "err", abortErr)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// uploadParts uploads data in parts and returns the completed parts in
+// order.
+func (u *Uploader) uploadParts(
+	ctx context.Context,
+	path string,
+	uploadID *string,
+	data []byte,
+) ([]types.CompletedPart, error) {
+	var parts []types.CompletedPart
+	for start := 0; start < len(data); start += u.PartSize {
+		chunk := data[start:min(start+u.PartSize, len(data))]
+		number := aws.Int32(int32(len(parts) + 1)) //nolint:gosec
+		var etag *string
+		err := u.retry(ctx, path, len(chunk), func(ctx context.Context) error {
+			out, err := u.Client.UploadPart(ctx, &s3.UploadPartInput{
+				Bucket:     &u.Bucket,
+				Key:        aws.String(path),
+				UploadId:   uploadID,
+				PartNumber: number,
+				Body:       bytes.NewReader(chunk),
+			})
+			if err != nil {
+				return err
+			}
+			etag = out.ETag
+
+			return nil
+		})
+		if err != nil {
+			return nil, eris.Wrapf(err, "failed to upload part %d: %s", *number, path)
+		}
+		parts = append(parts, types.CompletedPart{
+			ETag:       etag,
+			PartNumber: number,
+		})
+	}
+
+	return parts, nil
+}
+
+// retry calls fn until it succeeds, fails with an error that is not worth
+// retrying or runs out of attempts, backing off exponentially in between.
+// Every attempt is given time to send a body of size bytes.
+func (u *Uploader) retry(
+	ctx context.Context,
+	path string,
+	size int,
+	fn func(context.Context) error,
+) error {
+	var err error
+	for attempt := range max(1, u.MaxAttempts) {
+		if attempt > 0 {
+			delay := u.backoff(attempt)
+			slog.Warn("retrying S3 request", "path", path, "attempt", attempt+1, "delay", delay, "err", err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+		attemptCtx, cancel := context.WithTimeout(ctx, u.attemptTimeout(size))
+		err = fn(attemptCtx)
+		cancel()
+		if err == nil || !isRetryable(ctx, err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+// attemptTimeout returns how long a request sending size bytes may take.
+func (u *Uploader) attemptTimeout(size int) time.Duration {
+	if u.MinThroughput <= 0 {
+		return u.AttemptTimeout
+	}
+
+	return u.AttemptTimeout + time.Duration(size)*time.Second/time.Duration(u.MinThroughput)
+}
+
+// backoff returns the jittered delay before the given retry attempt.
+func (u *Uploader) backoff(attempt int) time.Duration {
+	delay := u.BaseDelay << (attempt - 1)
+	if delay <= 0 || (u.MaxDelay > 0 && delay > u.MaxDelay) {
+		delay = u.MaxDelay
+	}
+	if delay <= 1 {
+		return delay
+	}
+
+	return delay/2 + rand.N(delay/2) //nolint:gosec
+}
+
+// isRetryable returns true if a failed request is worth retrying: the
+// request timed out on its own, or S3 reported a transient failure.
+func isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	return retryables.IsErrorRetryable(err) == aws.TrueTernary
+}
+
+// trimETag returns an ETag without its surrounding quotes.
+func trimETag(etag *string) string {
+	return strings.Trim(aws.ToString(etag), `"`)
+}
+
+// cacheControl returns the Cache-Control header to set, if any.
+func (u *Uploader) cacheControl() *string {
+	if u.CacheControl == "" {
+		return nil
+	}
+
+	return aws.String(u.CacheControl)
+}
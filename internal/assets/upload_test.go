@@ -0,0 +1,490 @@
+package assets_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5" //nolint:gosec
+	"errors"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/conneroisu/conneroh.com/internal/assets"
+)
+
+// statusError is an S3 error response with an HTTP status code.
+type statusError int
+
+func (e statusError) Error() string       { return "status " + strconv.Itoa(int(e)) }
+func (e statusError) HTTPStatusCode() int { return int(e) }
+
+// fakeTigris is an in-memory S3-compatible bucket. Every call consumes one
+// entry of failures, if any are left, and fails with it instead.
+type fakeTigris struct {
+	mu       sync.Mutex
+	failures []error
+	objects  map[string][]byte
+	etags    map[string]string
+	types    map[string]string
+	uploads  map[string]map[int32][]byte
+	aborted  int
+	calls    int
+}
+
+func newFakeTigris(failures ...error) *fakeTigris {
+	return &fakeTigris{
+		failures: failures,
+		objects:  map[string][]byte{},
+		etags:    map[string]string{},
+		types:    map[string]string{},
+		uploads:  map[string]map[int32][]byte{},
+	}
+}
+
+func (f *fakeTigris) fail() error {
+	f.calls++
+	if len(f.failures) == 0 {
+		return nil
+	}
+	err := f.failures[0]
+	f.failures = f.failures[1:]
+
+	return err
+}
+
+func (f *fakeTigris) PutObject(
+	_ context.Context,
+	in *s3.PutObjectInput,
+	_ ...func(*s3.Options),
+) (*s3.PutObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.objects[*in.Key] = data
+	f.etags[*in.Key] = assets.ComputeHash(data)
+	f.types[*in.Key] = aws.ToString(in.ContentType)
+
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeTigris) CreateMultipartUpload(
+	_ context.Context,
+	in *s3.CreateMultipartUploadInput,
+	_ ...func(*s3.Options),
+) (*s3.CreateMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	id := "upload-" + strconv.Itoa(len(f.uploads))
+	f.uploads[id] = map[int32][]byte{}
+	f.types[*in.Key] = aws.ToString(in.ContentType)
+
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String(id)}, nil
+}
+
+func (f *fakeTigris) UploadPart(
+	_ context.Context,
+	in *s3.UploadPartInput,
+	_ ...func(*s3.Options),
+) (*s3.UploadPartOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.uploads[*in.UploadId][*in.PartNumber] = data
+
+	return &s3.UploadPartOutput{
+		ETag: aws.String("etag-" + strconv.Itoa(int(*in.PartNumber))),
+	}, nil
+}
+
+func (f *fakeTigris) CompleteMultipartUpload(
+	_ context.Context,
+	in *s3.CompleteMultipartUploadInput,
+	_ ...func(*s3.Options),
+) (*s3.CompleteMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	var data, sums []byte
+	for _, part := range in.MultipartUpload.Parts {
+		chunk := f.uploads[*in.UploadId][*part.PartNumber]
+		data = append(data, chunk...)
+		sum := md5.Sum(chunk) //nolint:gosec
+		sums = append(sums, sum[:]...)
+	}
+	delete(f.uploads, *in.UploadId)
+	f.objects[*in.Key] = data
+	f.etags[*in.Key] = assets.ComputeHash(sums) + "-" + strconv.Itoa(len(in.MultipartUpload.Parts))
+
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (f *fakeTigris) AbortMultipartUpload(
+	_ context.Context,
+	in *s3.AbortMultipartUploadInput,
+	_ ...func(*s3.Options),
+) (*s3.AbortMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.uploads, *in.UploadId)
+	f.aborted++
+
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+func (f *fakeTigris) HeadObject(
+	_ context.Context,
+	in *s3.HeadObjectInput,
+	_ ...func(*s3.Options),
+) (*s3.HeadObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	etag, ok := f.etags[*in.Key]
+	if !ok {
+		return nil, statusError(404)
+	}
+
+	return &s3.HeadObjectOutput{ETag: aws.String(`"` + etag + `"`)}, nil
+}
+
+// ListObjectsV2 lists two keys per page to exercise pagination.
+func (f *fakeTigris) ListObjectsV2(
+	_ context.Context,
+	in *s3.ListObjectsV2Input,
+	_ ...func(*s3.Options),
+) (*s3.ListObjectsV2Output, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	var keys []string
+	for key := range f.etags {
+		if strings.HasPrefix(key, aws.ToString(in.Prefix)) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	start, _ := strconv.Atoi(aws.ToString(in.ContinuationToken))
+	end := min(start+2, len(keys))
+	out := &s3.ListObjectsV2Output{IsTruncated: aws.Bool(end < len(keys))}
+	for _, key := range keys[start:end] {
+		out.Contents = append(out.Contents, types.Object{
+			Key:  aws.String(key),
+			ETag: aws.String(`"` + f.etags[key] + `"`),
+		})
+	}
+	if end < len(keys) {
+		out.NextContinuationToken = aws.String(strconv.Itoa(end))
+	}
+
+	return out, nil
+}
+
+// testUploader returns an Uploader with tiny parts and no real backoff.
+func testUploader(client assets.Tigris) *assets.Uploader {
+	u := assets.NewUploader(client, "bucket")
+	u.MultipartThreshold = 10
+	u.PartSize = 4
+	u.MaxAttempts = 3
+	u.BaseDelay = time.Microsecond
+	u.MaxDelay = time.Millisecond
+
+	return u
+}
+
+func TestUploaderUpload(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		failures    []error
+		wantErr     bool
+		wantCalls   int
+		wantAborted int
+	}{
+		{
+			name:      "Small file in one request",
+			data:      "tiny",
+			wantCalls: 1,
+		},
+		{
+			name: "Large file in parts",
+			data: "0123456789abcdef!",
+			// create, 5 parts, complete
+			wantCalls: 7,
+		},
+		{
+			name:      "Transient failures are retried",
+			data:      "tiny",
+			failures:  []error{statusError(503), statusError(500)},
+			wantCalls: 3,
+		},
+		{
+			name:      "Client errors are not retried",
+			data:      "tiny",
+			failures:  []error{statusError(403)},
+			wantErr:   true,
+			wantCalls: 1,
+		},
+		{
+			name:      "Attempts run out",
+			data:      "tiny",
+			failures:  []error{statusError(503), statusError(503), statusError(503)},
+			wantErr:   true,
+			wantCalls: 3,
+		},
+		{
+			name:     "Failed part is retried on its own",
+			data:     "0123456789abcdef!",
+			failures: []error{nil, nil, statusError(503)},
+			// create, part 1, failed part 2, 5 parts, complete
+			wantCalls: 8,
+		},
+		{
+			name:        "Failed multipart upload is aborted",
+			data:        "0123456789abcdef!",
+			failures:    []error{nil, errors.New("disk on fire")},
+			wantErr:     true,
+			wantCalls:   2,
+			wantAborted: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeTigris(tt.failures...)
+			err := testUploader(fake).Upload(context.Background(), "assets/file.pdf", []byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Upload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if fake.calls != tt.wantCalls {
+				t.Errorf("Upload() calls = %d, want %d", fake.calls, tt.wantCalls)
+			}
+			if fake.aborted != tt.wantAborted {
+				t.Errorf("Upload() aborted = %d, want %d", fake.aborted, tt.wantAborted)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := string(fake.objects["assets/file.pdf"]); got != tt.data {
+				t.Errorf("Upload() object = %q, want %q", got, tt.data)
+			}
+			if got := fake.types["assets/file.pdf"]; got != "application/pdf" {
+				t.Errorf("Upload() content type = %q, want %q", got, "application/pdf")
+			}
+		})
+	}
+}
+
+// slowTigris takes delay to answer every PutObject, giving up when the
+// request times out first.
+type slowTigris struct {
+	*fakeTigris
+	delay time.Duration
+}
+
+func (s slowTigris) PutObject(
+	ctx context.Context,
+	in *s3.PutObjectInput,
+	opts ...func(*s3.Options),
+) (*s3.PutObjectOutput, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(s.delay):
+	}
+
+	return s.fakeTigris.PutObject(ctx, in, opts...)
+}
+
+func TestUploaderAttemptTimeout(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          string
+		minThroughput int
+		wantErr       bool
+	}{
+		{
+			name:    "Slow request times out",
+			data:    "0123456789",
+			wantErr: true,
+		},
+		{
+			name: "Timeout grows with the body",
+			data: "0123456789",
+			// 10 bytes at 50 bytes per second take 200ms.
+			minThroughput: 50,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := testUploader(slowTigris{fakeTigris: newFakeTigris(), delay: 50 * time.Millisecond})
+			u.MaxAttempts = 1
+			u.AttemptTimeout = 10 * time.Millisecond
+			u.MinThroughput = tt.minThroughput
+			err := u.Upload(context.Background(), "assets/file.pdf", []byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Upload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUploaderUploadAll(t *testing.T) {
+	fake := newFakeTigris()
+	var files []assets.Upload
+	for i := range 20 {
+		files = append(files, assets.Upload{
+			Path: "assets/" + strconv.Itoa(i) + ".png",
+			Data: bytes.Repeat([]byte{byte(i)}, i+1),
+		})
+	}
+	err := testUploader(fake).UploadAll(context.Background(), files)
+	if err != nil {
+		t.Fatalf("UploadAll() error = %v", err)
+	}
+	for _, file := range files {
+		if !slices.Equal(fake.objects[file.Path], file.Data) {
+			t.Errorf("UploadAll() object %s = %v, want %v", file.Path, fake.objects[file.Path], file.Data)
+		}
+	}
+}
+
+func TestUploaderSkipUnchanged(t *testing.T) {
+	tests := []struct {
+		name       string
+		existing   string
+		data       string
+		wantUpload bool
+	}{
+		{name: "Missing object", data: "tiny", wantUpload: true},
+		{name: "Same object", existing: "tiny", data: "tiny"},
+		{name: "Changed object", existing: "old", data: "new", wantUpload: true},
+		{name: "Same multipart object", existing: "0123456789abcdef!", data: "0123456789abcdef!"},
+		{name: "Changed multipart object", existing: "0123456789abcdef!", data: "0123456789abcdef?", wantUpload: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeTigris()
+			u := testUploader(fake)
+			if tt.existing != "" {
+				if err := u.Upload(context.Background(), "assets/a.png", []byte(tt.existing)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			calls := fake.calls
+			u.SkipUnchanged = true
+			err := u.Upload(context.Background(), "assets/a.png", []byte(tt.data))
+			if err != nil {
+				t.Fatalf("Upload() error = %v", err)
+			}
+			// One call checks the remote object, any more upload it.
+			if uploaded := fake.calls-calls > 1; uploaded != tt.wantUpload {
+				t.Errorf("Upload() uploaded = %v, want %v", uploaded, tt.wantUpload)
+			}
+			if got := string(fake.objects["assets/a.png"]); got != tt.data {
+				t.Errorf("Upload() object = %q, want %q", got, tt.data)
+			}
+		})
+	}
+}
+
+func TestUploaderReconcile(t *testing.T) {
+	fake := newFakeTigris()
+	u := testUploader(fake)
+	for path, data := range map[string]string{
+		"assets/same.png":    "same",
+		"assets/changed.png": "before",
+		"assets/large.mp4":   "0123456789abcdef!",
+		"assets/stale.png":   "stale",
+		"other/ignored.txt":  "ignored",
+	} {
+		if err := u.Upload(context.Background(), path, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	remote, err := assets.RemoteETags(context.Background(), fake, "bucket", assets.AssetsLoc)
+	if err != nil {
+		t.Fatalf("RemoteETags() error = %v", err)
+	}
+	if len(remote) != 4 {
+		t.Errorf("RemoteETags() = %v, want 4 objects", remote)
+	}
+
+	pending, drift := u.Reconcile([]assets.Upload{
+		{Path: "assets/same.png", Data: []byte("same")},
+		{Path: "assets/changed.png", Data: []byte("after")},
+		{Path: "assets/large.mp4", Data: []byte("0123456789abcdef!")},
+		{Path: "assets/new.png", Data: []byte("new")},
+	}, remote)
+
+	var paths []string
+	for _, file := range pending {
+		paths = append(paths, file.Path)
+	}
+	if want := []string{"assets/changed.png", "assets/new.png"}; !slices.Equal(paths, want) {
+		t.Errorf("Reconcile() pending = %v, want %v", paths, want)
+	}
+	if want := []string{"assets/new.png"}; !slices.Equal(drift.Missing, want) {
+		t.Errorf("Reconcile() missing = %v, want %v", drift.Missing, want)
+	}
+	if want := []string{"assets/changed.png"}; !slices.Equal(drift.Changed, want) {
+		t.Errorf("Reconcile() changed = %v, want %v", drift.Changed, want)
+	}
+	if want := []string{"assets/stale.png"}; !slices.Equal(drift.Extra, want) {
+		t.Errorf("Reconcile() extra = %v, want %v", drift.Extra, want)
+	}
+	if drift.InSync != 2 {
+		t.Errorf("Reconcile() in sync = %d, want 2", drift.InSync)
+	}
+}
+
+func TestContentAddress(t *testing.T) {
+	data := []byte("pixels")
+	hash := assets.ComputeHash(data)[:12]
+	uploads, entries := assets.ContentAddress([]assets.Upload{
+		{Path: assets.AssetsLoc + "img/logo.png", Data: data},
+		{Path: "robots.txt", Data: data},
+	})
+
+	wantPaths := []string{assets.AssetsLoc + "img/logo." + hash + ".png", "robots.txt"}
+	for i, want := range wantPaths {
+		if uploads[i].Path != want {
+			t.Errorf("ContentAddress() path = %q, want %q", uploads[i].Path, want)
+		}
+	}
+	if len(entries) != 1 {
+		t.Fatalf("ContentAddress() entries = %d, want 1", len(entries))
+	}
+	if entries[0].Path != "img/logo.png" || entries[0].Key != "img/logo."+hash+".png" {
+		t.Errorf("ContentAddress() entry = %+v, want img/logo.png -> img/logo.%s.png", entries[0], hash)
+	}
+}
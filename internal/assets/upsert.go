@@ -26,10 +26,20 @@ func UpsertPost(
 		Model(post).
 		On("CONFLICT (slug) DO UPDATE").
 		Set("title = EXCLUDED.title").
+		Set("path = EXCLUDED.path").
 		Set("description = EXCLUDED.description").
 		Set("content = EXCLUDED.content").
 		Set("banner_path = EXCLUDED.banner_path").
 		Set("created_at = EXCLUDED.created_at").
+		Set("updated_at = EXCLUDED.updated_at").
+		Set("publish_at = EXCLUDED.publish_at").
+		Set("draft = EXCLUDED.draft").
+		Set("unlisted = EXCLUDED.unlisted").
+		Set("toc = EXCLUDED.toc").
+		Set("word_count = EXCLUDED.word_count").
+		Set("reading_time = EXCLUDED.reading_time").
+		Set("series_slug = EXCLUDED.series_slug").
+		Set("series_order = EXCLUDED.series_order").
 		Exec(insertCtx)
 	slog.Debug(
 		"saved post",
@@ -63,10 +73,18 @@ func UpsertProject(
 		Model(project).
 		On("CONFLICT (slug) DO UPDATE").
 		Set("title = EXCLUDED.title").
+		Set("path = EXCLUDED.path").
 		Set("description = EXCLUDED.description").
 		Set("content = EXCLUDED.content").
 		Set("banner_path = EXCLUDED.banner_path").
 		Set("created_at = EXCLUDED.created_at").
+		Set("updated_at = EXCLUDED.updated_at").
+		Set("publish_at = EXCLUDED.publish_at").
+		Set("draft = EXCLUDED.draft").
+		Set("unlisted = EXCLUDED.unlisted").
+		Set("toc = EXCLUDED.toc").
+		Set("word_count = EXCLUDED.word_count").
+		Set("reading_time = EXCLUDED.reading_time").
 		Exec(insertCtx)
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
@@ -99,6 +117,7 @@ func UpsertTag(
 		Set("content = EXCLUDED.content").
 		Set("banner_path = EXCLUDED.banner_path").
 		Set("created_at = EXCLUDED.created_at").
+		Set("updated_at = EXCLUDED.updated_at").
 		Exec(insertCtx)
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
@@ -121,6 +140,22 @@ func UpsertPostRelationships(
 	post *Post,
 ) RelationshipFn {
 	return func(ctx context.Context) error {
+		err := upsertMediaUsage(
+			ctx,
+			db,
+			post.MediaAlts,
+			post.Slug,
+			(*MediaToPost)(nil),
+			"post_id = ?",
+			post.ID,
+			func(media *Media) any {
+				return &MediaToPost{MediaID: media.ID, PostID: post.ID}
+			},
+		)
+		if err != nil {
+			return err
+		}
+
 		var relatedTag Tag
 		for _, tagSlug := range post.TagSlugs {
 			err := db.NewSelect().
@@ -266,6 +301,22 @@ func UpsertProjectRelationships(
 	project *Project,
 ) RelationshipFn {
 	return func(ctx context.Context) error {
+		err := upsertMediaUsage(
+			ctx,
+			db,
+			project.MediaAlts,
+			project.Slug,
+			(*MediaToProject)(nil),
+			"project_id = ?",
+			project.ID,
+			func(media *Media) any {
+				return &MediaToProject{MediaID: media.ID, ProjectID: project.ID}
+			},
+		)
+		if err != nil {
+			return err
+		}
+
 		var relatedTag Tag
 		for _, tagSlug := range project.TagSlugs { // Create tag relationships
 			err := db.NewSelect().
@@ -411,6 +462,11 @@ func UpsertTagRelationships(
 	tag *Tag,
 ) RelationshipFn {
 	return func(ctx context.Context) error {
+		_, err := findMedia(ctx, db, tag.BannerPath, tag.Slug)
+		if err != nil {
+			return err
+		}
+
 		var relatedTag Tag
 		for _, tagSlug := range tag.TagSlugs { // Create tag relationships
 			err := db.NewSelect().
@@ -566,6 +622,7 @@ func UpsertEmployment(
 		Set("content = EXCLUDED.content").
 		Set("banner_path = EXCLUDED.banner_path").
 		Set("created_at = EXCLUDED.created_at").
+		Set("updated_at = EXCLUDED.updated_at").
 		Exec(insertCtx)
 	slog.Debug(
 		"saved employment",
@@ -593,6 +650,11 @@ func UpsertEmploymentRelationships(
 	employment *Employment,
 ) RelationshipFn {
 	return func(ctx context.Context) error {
+		_, err := findMedia(ctx, db, employment.BannerPath, employment.Slug)
+		if err != nil {
+			return err
+		}
+
 		var relatedTag Tag
 		for _, tagSlug := range employment.TagSlugs {
 			err := db.NewSelect().
@@ -776,3 +838,221 @@ func UpsertEmploymentRelationships(
 		return nil
 	}
 }
+
+// UpsertRevisions replaces the stored revisions of the document at docPath.
+func UpsertRevisions(
+	ctx context.Context,
+	db *bun.DB,
+	docPath string,
+	revisions []*Revision,
+) error {
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewDelete().
+			Model((*Revision)(nil)).
+			Where("doc_path = ?", docPath).
+			Exec(ctx)
+		if err != nil {
+			return eris.Wrapf(err, "failed to clear revisions: %s", docPath)
+		}
+		if len(revisions) == 0 {
+			return nil
+		}
+		_, err = tx.NewInsert().
+			Model(&revisions).
+			Exec(ctx)
+		if err != nil {
+			return eris.Wrapf(err, "failed to save revisions: %s", docPath)
+		}
+
+		return nil
+	})
+}
+
+// UpsertSeries saves a series to the database.
+func UpsertSeries(
+	ctx context.Context,
+	db *bun.DB,
+	series *Series,
+) error {
+	insertCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := db.NewInsert().
+		Model(series).
+		On("CONFLICT (slug) DO UPDATE").
+		Set("title = EXCLUDED.title").
+		Exec(insertCtx)
+	if err != nil {
+		return eris.Wrapf(err, "failed to save series: %s", series.Slug)
+	}
+
+	return nil
+}
+
+// UpsertImageVariants replaces the stored variants of the image at path.
+func UpsertImageVariants(
+	ctx context.Context,
+	db *bun.DB,
+	path string,
+	variants []*ImageVariant,
+) error {
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewDelete().
+			Model((*ImageVariant)(nil)).
+			Where("path = ?", path).
+			Exec(ctx)
+		if err != nil {
+			return eris.Wrapf(err, "failed to clear image variants: %s", path)
+		}
+		if len(variants) == 0 {
+			return nil
+		}
+		_, err = tx.NewInsert().
+			Model(&variants).
+			Exec(ctx)
+		if err != nil {
+			return eris.Wrapf(err, "failed to save image variants: %s", path)
+		}
+
+		return nil
+	})
+}
+
+// UpsertMedia saves the metadata of an asset to the database.
+func UpsertMedia(
+	ctx context.Context,
+	db *bun.DB,
+	media *Media,
+) error {
+	_, err := db.NewInsert().
+		Model(media).
+		On("CONFLICT (path) DO UPDATE").
+		Set("content_type = EXCLUDED.content_type").
+		Set("size = EXCLUDED.size").
+		Set("width = EXCLUDED.width").
+		Set("height = EXCLUDED.height").
+		Set("dominant_color = EXCLUDED.dominant_color").
+		Set("placeholder = EXCLUDED.placeholder").
+		Set("poster = EXCLUDED.poster").
+		Exec(ctx)
+	if err != nil {
+		return eris.Wrapf(err, "failed to save media: %s", media.Path)
+	}
+
+	return nil
+}
+
+// upsertMediaUsage replaces the recorded media used by a document with the
+// assets in alts, updating their alt text along the way.
+//
+// Assets without a media row, such as banner paths and embeds pointing at
+// missing files, are reported by findMedia and skipped.
+func upsertMediaUsage(
+	ctx context.Context,
+	db *bun.DB,
+	alts map[string]string,
+	slug string,
+	joinModel any,
+	where string,
+	id int64,
+	link func(*Media) any,
+) error {
+	_, err := db.NewDelete().
+		Model(joinModel).
+		Where(where, id).
+		Exec(ctx)
+	if err != nil {
+		return eris.Wrapf(err, "failed to clear media usage of %s", slug)
+	}
+	for path, alt := range alts {
+		media, err := findMedia(ctx, db, path, slug)
+		if err != nil {
+			return err
+		}
+		if media == nil {
+			continue
+		}
+		if alt != "" && alt != media.Alt {
+			_, err = db.NewUpdate().
+				Model(media).
+				Set("alt = ?", alt).
+				WherePK().
+				Exec(ctx)
+			if err != nil {
+				return eris.Wrapf(err, "failed to update alt text: %s", path)
+			}
+		}
+		_, err = db.NewInsert().
+			Model(link(media)).
+			Ignore().
+			Exec(ctx)
+		if err != nil {
+			return eris.Wrapf(
+				err,
+				"failed to create media relationship: %s -> %s",
+				slug,
+				path,
+			)
+		}
+	}
+
+	return nil
+}
+
+// findMedia returns the media row of the asset at path referenced from the
+// document with the given slug. An empty path, such as an unset banner, is
+// not looked up.
+//
+// A reference to an asset without a media row is logged as a warning and
+// yields nil, so that one missing file does not keep the rest of the content
+// from being published.
+func findMedia(
+	ctx context.Context,
+	db *bun.DB,
+	path string,
+	slug string,
+) (*Media, error) {
+	var media Media
+	if path == "" {
+		return &media, nil
+	}
+	err := db.NewSelect().
+		Model(&media).
+		Where("path = ?", path).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		slog.WarnContext(
+			ctx,
+			"document references a missing asset: "+slug+" uses "+VaultLoc+AssetsLoc+path,
+			slog.String("document", slug),
+			slog.String("asset", path),
+		)
+
+		return nil, nil
+	}
+	if err != nil {
+		return nil, eris.Wrapf(err, "failed to find media: %s", path)
+	}
+
+	return &media, nil
+}
+
+// UpsertManifest saves the content-addressed keys of published files.
+func UpsertManifest(
+	ctx context.Context,
+	db *bun.DB,
+	entries []*ManifestEntry,
+) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	_, err := db.NewInsert().
+		Model(&entries).
+		On("CONFLICT (path) DO UPDATE").
+		Set("key = EXCLUDED.key").
+		Exec(ctx)
+	if err != nil {
+		return eris.Wrap(err, "failed to save manifest")
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package assets_test
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/conneroisu/conneroh.com/internal/assets"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+)
+
+// openDB returns a migrated in-memory database closed at the end of the test.
+func openDB(t *testing.T) *bun.DB {
+	t.Helper()
+	sqldb, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqldb.SetMaxOpenConns(1)
+	db := bun.NewDB(sqldb, sqlitedialect.New())
+	t.Cleanup(func() { _ = db.Close() })
+	assets.RegisterModels(db)
+	if err := assets.InitDB(context.Background(), db); err != nil {
+		t.Fatalf("InitDB() error = %v", err)
+	}
+
+	return db
+}
+
+// day returns midnight UTC of the given day of 2026.
+func day(month time.Month, d int) assets.CustomTime {
+	return assets.CustomTime{Time: time.Date(2026, month, d, 0, 0, 0, 0, time.UTC)}
+}
+
+func TestUpsertPostUpdatedAt(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	upsert := func(slug string, updated assets.CustomTime) {
+		t.Helper()
+		post := &assets.Post{Slug: slug, CreatedAt: day(time.January, 1), UpdatedAt: updated}
+		if _, err := assets.UpsertPost(ctx, db, post); err != nil {
+			t.Fatalf("UpsertPost(%s) error = %v", slug, err)
+		}
+	}
+	order := func() []string {
+		t.Helper()
+		var posts []*assets.Post
+		err := db.NewSelect().Model(&posts).Order("updated_at DESC").Scan(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return slugs(posts)
+	}
+
+	upsert("old", day(time.February, 1))
+	upsert("new", day(time.March, 1))
+	if got, want := order(), []string{"new", "old"}; !slices.Equal(got, want) {
+		t.Errorf("order after insert = %v, want %v", got, want)
+	}
+
+	// Editing the old post moves it to the front.
+	upsert("old", day(time.April, 1))
+	if got, want := order(), []string{"old", "new"}; !slices.Equal(got, want) {
+		t.Errorf("order after update = %v, want %v", got, want)
+	}
+	var post assets.Post
+	err := db.NewSelect().Model(&post).Where("slug = ?", "old").Scan(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := day(time.April, 1); !post.UpdatedAt.Equal(want.Time) {
+		t.Errorf("UpdatedAt = %v, want %v", post.UpdatedAt, want)
+	}
+	if want := day(time.January, 1); !post.CreatedAt.Equal(want.Time) {
+		t.Errorf("CreatedAt = %v, want %v", post.CreatedAt, want)
+	}
+}
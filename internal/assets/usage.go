@@ -0,0 +1,112 @@
+package assets
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/rotisserie/eris"
+	"github.com/uptrace/bun"
+	"github.com/yuin/goldmark/ast"
+	"go.abhg.dev/goldmark/wikilink"
+)
+
+// embedSize matches the Obsidian embed labels that size an image, e.g. "300"
+// or "300x200", rather than describe it.
+var embedSize = regexp.MustCompile(`^\d+(x\d+)?$`)
+
+// MediaReferences walks a parsed markdown document and returns the assets it
+// links to or embeds, mapped to the alt text they are embedded with.
+//
+// Assets are referenced by wikilinks, e.g. ![[banner.png|A banner]], or by
+// images pointing into the bucket.
+func MediaReferences(root ast.Node, source []byte) map[string]string {
+	refs := map[string]string{}
+	_ = ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := n.(type) {
+		case *wikilink.Node:
+			target := string(node.Target)
+			if target == "" || strings.HasPrefix(target, "http") {
+				return ast.WalkSkipChildren, nil
+			}
+			var alt string
+			label := plainText(node, source)
+			if node.Embed && label != target && !embedSize.MatchString(label) {
+				alt = label
+			}
+			addReference(refs, target, alt)
+
+			return ast.WalkSkipChildren, nil
+		case *ast.Image:
+			path, ok := strings.CutPrefix(string(node.Destination), BucketPath(""))
+			if ok {
+				addReference(refs, path, plainText(node, source))
+			}
+
+			return ast.WalkSkipChildren, nil
+		}
+
+		return ast.WalkContinue, nil
+	})
+
+	return refs
+}
+
+// addReference records a reference to the asset at path, keeping the first
+// alt text it was given.
+func addReference(refs map[string]string, path, alt string) {
+	if refs[path] == "" {
+		refs[path] = strings.TrimSpace(alt)
+	}
+}
+
+// DocsUsing returns the markdown paths of the posts and projects that use any
+// of the assets at paths, whose rendered content links to the assets'
+// content-addressed keys and so must be rendered again when they change.
+func DocsUsing(
+	ctx context.Context,
+	db *bun.DB,
+	paths []string,
+) ([]string, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	var (
+		posts    []*Post
+		projects []*Project
+		docs     []string
+	)
+	err := db.NewSelect().
+		Model(&posts).
+		Column("post.path").
+		Join("JOIN media_to_posts AS mp ON mp.post_id = post.id").
+		Join("JOIN media ON media.id = mp.media_id").
+		Where("media.path IN (?)", bun.In(paths)).
+		Distinct().
+		Scan(ctx)
+	if err != nil {
+		return nil, eris.Wrap(err, "failed to find posts using media")
+	}
+	for _, post := range posts {
+		docs = append(docs, post.Path)
+	}
+	err = db.NewSelect().
+		Model(&projects).
+		Column("project.path").
+		Join("JOIN media_to_projects AS mp ON mp.project_id = project.id").
+		Join("JOIN media ON media.id = mp.media_id").
+		Where("media.path IN (?)", bun.In(paths)).
+		Distinct().
+		Scan(ctx)
+	if err != nil {
+		return nil, eris.Wrap(err, "failed to find projects using media")
+	}
+	for _, project := range projects {
+		docs = append(docs, project.Path)
+	}
+
+	return docs, nil
+}
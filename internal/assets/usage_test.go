@@ -0,0 +1,98 @@
+package assets_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/conneroisu/conneroh.com/internal/assets"
+	"github.com/uptrace/bun"
+)
+
+func TestMediaUsage(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	for _, path := range []string{"a.png", "b.png"} {
+		if err := assets.UpsertMedia(ctx, db, &assets.Media{Path: path, ContentType: "image/png"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var logs bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	// used returns the paths of the media used by the post and their alt
+	// text.
+	used := func(slug string) []string {
+		t.Helper()
+		var post assets.Post
+		err := db.NewSelect().Model(&post).
+			Where("slug = ?", slug).
+			Relation("Media", func(q *bun.SelectQuery) *bun.SelectQuery {
+				return q.Order("path")
+			}).
+			Scan(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []string{}
+		for _, media := range post.Media {
+			got = append(got, media.Path+"="+media.Alt)
+		}
+
+		return got
+	}
+
+	tests := []struct {
+		name   string
+		banner string
+		alts   map[string]string
+		want   []string
+		// wantWarning is part of the expected warning, "" for none.
+		wantWarning string
+	}{
+		{
+			name: "Usage recorded",
+			alts: map[string]string{"a.png": "A diagram", "b.png": ""},
+			want: []string{"a.png=A diagram", "b.png="},
+		},
+		{
+			name: "Stale usage removed",
+			alts: map[string]string{"b.png": "A photo"},
+			want: []string{"b.png=A photo"},
+		},
+		{
+			name:        "Missing banner",
+			banner:      "missing.png",
+			alts:        map[string]string{"missing.png": "Post", "a.png": ""},
+			want:        []string{"a.png=A diagram"},
+			wantWarning: "post uses internal/data/assets/missing.png",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.Reset()
+			post := &assets.Post{Slug: "post", BannerPath: tt.banner, MediaAlts: tt.alts}
+			relate, err := assets.UpsertPost(ctx, db, post)
+			if err != nil {
+				t.Fatalf("UpsertPost() error = %v", err)
+			}
+			if err := relate(ctx); err != nil {
+				t.Fatalf("UpsertPost() relationships error = %v", err)
+			}
+			if got := used("post"); !slices.Equal(got, tt.want) {
+				t.Errorf("media used = %v, want %v", got, tt.want)
+			}
+			if tt.wantWarning == "" && logs.Len() > 0 {
+				t.Errorf("logged %s, want nothing", logs.String())
+			}
+			if tt.wantWarning != "" && !strings.Contains(logs.String(), tt.wantWarning) {
+				t.Errorf("logged %q, want a warning with %q", logs.String(), tt.wantWarning)
+			}
+		})
+	}
+}
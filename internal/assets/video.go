@@ -0,0 +1,193 @@
+package assets
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/rotisserie/eris"
+	"github.com/spf13/afero"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+	"go.abhg.dev/goldmark/wikilink"
+)
+
+// PosterExtractor pulls a still frame out of a video to show before it plays.
+type PosterExtractor interface {
+	// Poster returns a JPEG frame of the video at path.
+	Poster(ctx context.Context, path string, video []byte) ([]byte, error)
+}
+
+// FFmpegExtractor is a PosterExtractor backed by the ffmpeg executable.
+type FFmpegExtractor struct{}
+
+// Poster picks a representative frame of the video with ffmpeg's thumbnail
+// filter.
+func (FFmpegExtractor) Poster(
+	ctx context.Context,
+	path string,
+	video []byte,
+) ([]byte, error) {
+	if err := lookPath("ffmpeg", "video posters"); err != nil {
+		return nil, eris.Wrap(err, path)
+	}
+	dir, err := os.MkdirTemp("", "poster")
+	if err != nil {
+		return nil, eris.Wrap(err, "failed to create temporary directory")
+	}
+	defer os.RemoveAll(dir)
+	in := filepath.Join(dir, "in"+filepath.Ext(path))
+	out := filepath.Join(dir, "poster.jpg")
+	err = os.WriteFile(in, video, 0o600)
+	if err != nil {
+		return nil, eris.Wrap(err, "failed to write video")
+	}
+	output, err := exec.CommandContext(
+		ctx,
+		"ffmpeg",
+		"-y", "-loglevel", "error",
+		"-i", in,
+		"-vf", "thumbnail",
+		"-frames:v", "1",
+		out,
+	).CombinedOutput()
+	if err != nil {
+		return nil, eris.Wrapf(err, "ffmpeg failed: %s", output)
+	}
+
+	return os.ReadFile(out)
+}
+
+// IsVideo returns true if the asset at path is a video.
+func IsVideo(path string) bool {
+	return isVideoType(GetContentType(path))
+}
+
+// PosterKey returns the asset path of the poster frame of the video at path,
+// e.g. "demo.mp4" becomes "demo.poster.jpg".
+func PosterKey(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".poster.jpg"
+}
+
+// CaptionsKey returns the asset path of the WebVTT captions of the video at
+// path, e.g. "demo.mp4" becomes "demo.vtt".
+func CaptionsKey(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".vtt"
+}
+
+// DescribeVideo extracts the poster frame of the video at path and returns
+// the video's metadata, measured from its poster, along with the poster.
+//
+// If the extractor cannot handle the video the error wraps
+// ErrUnsupportedFormat and the metadata is still returned without a poster.
+func DescribeVideo(
+	ctx context.Context,
+	ex PosterExtractor,
+	path string,
+	data []byte,
+) (*Media, []byte, error) {
+	media, err := DescribeAsset(path, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	poster, err := ex.Poster(ctx, path, data)
+	if err != nil {
+		return media, nil, eris.Wrapf(err, "failed to extract poster: %s", path)
+	}
+	frame, err := DescribeAsset(PosterKey(path), poster)
+	if err != nil {
+		return nil, nil, err
+	}
+	media.Poster = frame.Path
+	media.Width = frame.Width
+	media.Height = frame.Height
+	media.DominantColor = frame.DominantColor
+	media.Placeholder = frame.Placeholder
+
+	return media, poster, nil
+}
+
+// videoRenderer renders embedded videos, ![[demo.mp4]], as HTML5 players and
+// leaves every other wikilink to the wikilink renderer.
+type videoRenderer struct {
+	fs     afero.Fs
+	lookup func(path string) *Media
+	links  *wikilink.Renderer
+}
+
+// RegisterFuncs registers the wikilink rendering function.
+func (r *videoRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(wikilink.Kind, r.render)
+}
+
+func (r *videoRenderer) render(
+	w util.BufWriter,
+	source []byte,
+	node ast.Node,
+	entering bool,
+) (ast.WalkStatus, error) {
+	n, ok := node.(*wikilink.Node)
+	if !ok || !n.Embed || !IsVideo(string(n.Target)) {
+		return r.links.Render(w, source, node, entering)
+	}
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	path := string(n.Target)
+	if _, err := r.fs.Stat("/" + AssetsLoc + path); err != nil {
+		return ast.WalkStop, eris.Wrapf(err, "failed to find video: %s", path)
+	}
+	var media *Media
+	if r.lookup != nil {
+		media = r.lookup(path)
+	}
+
+	_, _ = w.WriteString(`<video controls preload="metadata" playsinline`)
+	if media != nil && media.Poster != "" {
+		fmt.Fprintf(w, ` poster="%s"`, html.EscapeString(AssetURL(media.Poster)))
+	}
+	if media != nil && media.Width > 0 {
+		fmt.Fprintf(w, ` width="%d" height="%d"`, media.Width, media.Height)
+	}
+	if label := plainText(n, source); label != path && !embedSize.MatchString(label) {
+		fmt.Fprintf(w, ` aria-label="%s"`, html.EscapeString(label))
+	}
+	_, _ = w.WriteString(`>`)
+	fmt.Fprintf(
+		w,
+		`<source src="%s" type="%s">`,
+		html.EscapeString(AssetURL(path)),
+		GetContentType(path),
+	)
+	if _, err := r.fs.Stat("/" + AssetsLoc + CaptionsKey(path)); err == nil {
+		fmt.Fprintf(
+			w,
+			`<track kind="captions" src="%s" srclang="en" label="English" default>`,
+			html.EscapeString(AssetURL(CaptionsKey(path))),
+		)
+	}
+	fmt.Fprintf(
+		w,
+		`<a href="%s">Download the video</a></video>`,
+		html.EscapeString(AssetURL(path)),
+	)
+
+	return ast.WalkSkipChildren, nil
+}
+
+// videoExtension installs the video embed renderer ahead of the wikilink
+// renderer.
+type videoExtension struct{ r *videoRenderer }
+
+// Extend implements goldmark.Extender.
+func (e *videoExtension) Extend(md goldmark.Markdown) {
+	md.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(e.r, 100),
+	))
+}
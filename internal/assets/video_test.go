@@ -0,0 +1,163 @@
+package assets_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"strings"
+	"testing"
+
+	"github.com/conneroisu/conneroh.com/internal/assets"
+	"github.com/rotisserie/eris"
+	"github.com/spf13/afero"
+)
+
+// fakeExtractor returns a solid frame of a fixed size for every video,
+// standing in for ffmpeg.
+type fakeExtractor struct {
+	width, height int
+	err           error
+}
+
+func (f fakeExtractor) Poster(
+	_ context.Context,
+	_ string,
+	_ []byte,
+) ([]byte, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	img := image.NewRGBA(image.Rect(0, 0, f.width, f.height))
+	for y := range f.height {
+		for x := range f.width {
+			img.Set(x, y, color.RGBA{R: 0x20, G: 0x80, B: 0x40, A: 0xFF})
+		}
+	}
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, img, nil)
+
+	return buf.Bytes(), err
+}
+
+func TestDescribeVideo(t *testing.T) {
+	tests := []struct {
+		name       string
+		ex         fakeExtractor
+		wantPoster string
+		wantWidth  int
+		wantErr    error
+	}{
+		{
+			name:       "Poster extracted",
+			ex:         fakeExtractor{width: 64, height: 36},
+			wantPoster: "clips/demo.poster.jpg",
+			wantWidth:  64,
+		},
+		{
+			name:    "Extractor unavailable",
+			ex:      fakeExtractor{err: assets.ErrUnsupportedFormat},
+			wantErr: assets.ErrUnsupportedFormat,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			media, poster, err := assets.DescribeVideo(
+				context.Background(),
+				tt.ex,
+				"clips/demo.mp4",
+				[]byte("not really a video"),
+			)
+			if !eris.Is(err, tt.wantErr) {
+				t.Fatalf("DescribeVideo() error = %v, want %v", err, tt.wantErr)
+			}
+			if media == nil {
+				t.Fatal("DescribeVideo() media = nil")
+			}
+			if media.ContentType != "video/mp4" {
+				t.Errorf("DescribeVideo() content type = %q, want %q", media.ContentType, "video/mp4")
+			}
+			if media.Poster != tt.wantPoster {
+				t.Errorf("DescribeVideo() poster = %q, want %q", media.Poster, tt.wantPoster)
+			}
+			if media.Width != tt.wantWidth {
+				t.Errorf("DescribeVideo() width = %d, want %d", media.Width, tt.wantWidth)
+			}
+			if (poster != nil) != (tt.wantPoster != "") {
+				t.Errorf("DescribeVideo() poster data = %d bytes", len(poster))
+			}
+		})
+	}
+}
+
+func TestParseMarkdownVideoEmbed(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	for _, path := range []string{"/assets/demo.mp4", "/assets/demo.vtt", "/assets/plain.webm"} {
+		if err := afero.WriteFile(fs, path, []byte("data"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	md := assets.NewMD(fs, assets.WithMedia(func(path string) *assets.Media {
+		if path != "demo.mp4" {
+			return nil
+		}
+
+		return &assets.Media{Path: path, Poster: "demo.poster.jpg", Width: 640, Height: 360}
+	}))
+
+	tests := []struct {
+		name     string
+		content  string
+		contains []string
+		excludes []string
+	}{
+		{
+			name:    "Poster and captions",
+			content: "![[demo.mp4|A short demo]]",
+			contains: []string{
+				`<video controls`,
+				`poster="` + assets.BucketPath("demo.poster.jpg") + `"`,
+				`width="640" height="360"`,
+				`aria-label="A short demo"`,
+				`<source src="` + assets.BucketPath("demo.mp4") + `" type="video/mp4">`,
+				`<track kind="captions" src="` + assets.BucketPath("demo.vtt") + `"`,
+			},
+		},
+		{
+			name:     "Without metadata",
+			content:  "![[plain.webm]]",
+			contains: []string{`<source src="` + assets.BucketPath("plain.webm") + `" type="video/webm">`},
+			excludes: []string{"poster=", "<track", "aria-label"},
+		},
+		{
+			name:     "Links are left alone",
+			content:  "[[demo.mp4]]",
+			contains: []string{`<a href="` + assets.BucketPath("demo.mp4") + `">`},
+			excludes: []string{"<video"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := assets.ParseMarkdown(md, assets.DirMatchItem{
+				Path:    "posts/video.md",
+				Content: "---\ntitle: Video\n---\n" + tt.content + "\n",
+			})
+			if err != nil {
+				t.Fatalf("ParseMarkdown() error = %v", err)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(doc.Content, want) {
+					t.Errorf("ParseMarkdown() content = %s, want it to contain %s", doc.Content, want)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(doc.Content, unwanted) {
+					t.Errorf("ParseMarkdown() content = %s, want it not to contain %s", doc.Content, unwanted)
+				}
+			}
+		})
+	}
+}
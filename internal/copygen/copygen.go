@@ -11,14 +11,25 @@ func ToPost(tP *assets.Post, fD *assets.Doc) {
 	// *assets.Post fields
 	tP.Title = fD.Title
 	tP.Slug = fD.Slug
+	tP.Path = fD.Path
 	tP.Description = fD.Description
 	tP.Content = fD.Content
 	tP.BannerPath = fD.BannerPath
 	tP.CreatedAt = fD.CreatedAt
+	tP.UpdatedAt = fD.UpdatedAt
+	tP.PublishAt = fD.PublishAt
+	tP.Draft = fD.Draft
+	tP.Unlisted = fD.Unlisted
+	tP.TOC = fD.TOC
+	tP.WordCount = fD.WordCount
+	tP.ReadingTime = fD.ReadingTime
+	tP.SeriesSlug = fD.SeriesSlug
+	tP.SeriesOrder = fD.SeriesOrder
 	tP.TagSlugs = fD.TagSlugs
 	tP.PostSlugs = fD.PostSlugs
 	tP.ProjectSlugs = fD.ProjectSlugs
 	tP.EmploymentSlugs = fD.EmploymentSlugs
+	tP.MediaAlts = fD.MediaAlts
 	tP.Tags = fD.Tags
 	tP.Projects = fD.Projects
 	tP.Employments = fD.Employments
@@ -29,14 +40,23 @@ func ToProject(tP *assets.Project, fD *assets.Doc) {
 	// *assets.Project fields
 	tP.Title = fD.Title
 	tP.Slug = fD.Slug
+	tP.Path = fD.Path
 	tP.Description = fD.Description
 	tP.Content = fD.Content
 	tP.BannerPath = fD.BannerPath
 	tP.CreatedAt = fD.CreatedAt
+	tP.UpdatedAt = fD.UpdatedAt
+	tP.PublishAt = fD.PublishAt
+	tP.Draft = fD.Draft
+	tP.Unlisted = fD.Unlisted
+	tP.TOC = fD.TOC
+	tP.WordCount = fD.WordCount
+	tP.ReadingTime = fD.ReadingTime
 	tP.TagSlugs = fD.TagSlugs
 	tP.PostSlugs = fD.PostSlugs
 	tP.ProjectSlugs = fD.ProjectSlugs
 	tP.EmploymentSlugs = fD.EmploymentSlugs
+	tP.MediaAlts = fD.MediaAlts
 	tP.Employments = fD.Employments
 }
 
@@ -50,6 +70,7 @@ func ToTag(tT *assets.Tag, fD *assets.Doc) {
 	tT.BannerPath = fD.BannerPath
 	tT.Icon = fD.Icon
 	tT.CreatedAt = fD.CreatedAt
+	tT.UpdatedAt = fD.UpdatedAt
 	tT.TagSlugs = fD.TagSlugs
 	tT.PostSlugs = fD.PostSlugs
 	tT.ProjectSlugs = fD.ProjectSlugs
@@ -67,6 +88,7 @@ func ToEmployment(tE *assets.Employment, fD *assets.Doc) {
 	tE.Content = fD.Content
 	tE.BannerPath = fD.BannerPath
 	tE.CreatedAt = fD.CreatedAt
+	tE.UpdatedAt = fD.UpdatedAt
 	tE.EndDate = fD.EndDate
 	tE.TagSlugs = fD.TagSlugs
 	tE.PostSlugs = fD.PostSlugs
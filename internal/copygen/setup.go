@@ -5,7 +5,7 @@ import "github.com/conneroisu/conneroh.com/internal/assets"
 
 /* Copygen defines the functions that are generated. */
 type Copygen interface {
-	// depth domain.Post 2
+	// depth assets.Post.Series 0
 	ToPost(*assets.Doc) *assets.Post
 	// depth domain.Project 2
 	ToProject(*assets.Doc) *assets.Project
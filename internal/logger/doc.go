@@ -1,2 +1,3 @@
-// Package logger provides loggers for different environments.
+// Package logger builds the structured loggers of the programs, whose level
+// can be changed while they run.
 package logger
@@ -0,0 +1,98 @@
+package logger
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/rotisserie/eris"
+)
+
+// maxLevelBody bounds the body of a request changing the level.
+const maxLevelBody = 64
+
+// ParseLevel parses a level name such as "debug" or "WARN", optionally with
+// an offset like "info+2".
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(strings.TrimSpace(s)))
+	if err != nil {
+		return level, eris.Errorf("invalid log level: %q", s)
+	}
+
+	return level, nil
+}
+
+// LevelHandler serves level: GET reports it and PUT sets it to the level
+// named in the request body.
+func LevelHandler(level *slog.LevelVar) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPut:
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxLevelBody))
+			if err != nil {
+				http.Error(w, "level too long", http.StatusRequestEntityTooLarge)
+
+				return
+			}
+			next, err := ParseLevel(string(body))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+
+				return
+			}
+			setLevel(r.Context(), level, next, "admin endpoint")
+		default:
+			w.Header().Set("Allow", "GET, HEAD, PUT")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+			return
+		}
+		_, _ = io.WriteString(w, level.Level().String()+"\n")
+	})
+}
+
+// ToggleDebugOn switches level between base and debug every time the process
+// receives one of signals, until ctx is done.
+func ToggleDebugOn(
+	ctx context.Context,
+	level *slog.LevelVar,
+	base slog.Level,
+	signals ...os.Signal,
+) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, signals...)
+	defer signal.Stop(c)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-c:
+			next := slog.LevelDebug
+			if level.Level() == slog.LevelDebug {
+				next = base
+			}
+			setLevel(ctx, level, next, sig.String())
+		}
+	}
+}
+
+// setLevel changes level to next. The change is logged as a warning, so that
+// it shows unless only errors are logged.
+func setLevel(ctx context.Context, level *slog.LevelVar, next slog.Level, by string) {
+	prev := level.Level()
+	level.Set(next)
+	slog.WarnContext(
+		ctx,
+		"log level changed",
+		slog.String("from", prev.String()),
+		slog.String("to", next.String()),
+		slog.String("by", by),
+	)
+}
@@ -0,0 +1,94 @@
+package logger
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/rotisserie/eris"
+)
+
+const (
+	// FormatText logs records as key=value pairs.
+	FormatText = "text"
+	// FormatJSON logs records as JSON objects, one per line.
+	FormatJSON = "json"
+)
+
+// ErrUnknownFormat is returned by New for an unsupported format.
+var ErrUnknownFormat = eris.New("unknown log format")
+
+var (
+	// DefaultLogger logs text records at the debug level to stdout.
+	DefaultLogger = must(New(os.Stdout, Options{Level: slog.LevelDebug}))
+	// DefaultProdLogger logs text records at the info level to stdout.
+	DefaultProdLogger = must(New(os.Stdout, Options{Level: slog.LevelInfo}))
+)
+
+// Options configures the loggers built by New.
+type Options struct {
+	// Format is FormatText, the default, or FormatJSON.
+	Format string
+	// Level is the minimum level logged. Pass a *slog.LevelVar to change it
+	// while the program runs.
+	Level slog.Leveler
+	// Timestamps adds the time to every record. It is off by default, as the
+	// platforms running the programs add it to every line they collect.
+	Timestamps bool
+}
+
+// New returns a logger writing records to w as opts describes. Records
+// logged with a context carry the attributes added to it by WithAttrs.
+func New(w io.Writer, opts Options) (*slog.Logger, error) {
+	hopts := &slog.HandlerOptions{
+		AddSource: true,
+		Level:     opts.Level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) > 0 {
+				return a
+			}
+			switch a.Key {
+			case slog.TimeKey:
+				if !opts.Timestamps {
+					return slog.Attr{}
+				}
+			case slog.SourceKey:
+				trimSource(a)
+			}
+
+			return a
+		},
+	}
+	var h slog.Handler
+	switch opts.Format {
+	case "", FormatText:
+		h = slog.NewTextHandler(w, hopts)
+	case FormatJSON:
+		h = slog.NewJSONHandler(w, hopts)
+	default:
+		return nil, eris.Wrapf(ErrUnknownFormat, "%q", opts.Format)
+	}
+
+	return slog.New(NewContextHandler(h)), nil
+}
+
+// trimSource shortens the source of a record to its package directory and
+// file.
+func trimSource(a slog.Attr) {
+	if src, ok := a.Value.Any().(*slog.Source); ok {
+		split := strings.Split(src.File, "/")
+		if len(split) > 2 {
+			src.File = strings.Join(split[len(split)-2:], "/")
+		}
+	}
+}
+
+// must panics if a default logger cannot be built.
+func must(l *slog.Logger, err error) *slog.Logger {
+	if err != nil {
+		panic(err)
+	}
+
+	return l
+}
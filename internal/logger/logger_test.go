@@ -0,0 +1,104 @@
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/conneroisu/conneroh.com/internal/logger"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    logger.Options
+		want    []string
+		exclude []string
+	}{
+		{
+			name:    "text",
+			opts:    logger.Options{Level: slog.LevelInfo},
+			want:    []string{"level=INFO", "msg=hello", "request_id=abc", "source=logger/logger_test.go:"},
+			exclude: []string{"time="},
+		},
+		{
+			name: "text with timestamps",
+			opts: logger.Options{Level: slog.LevelInfo, Timestamps: true},
+			want: []string{"time=", "level=INFO"},
+		},
+		{
+			name:    "json",
+			opts:    logger.Options{Format: logger.FormatJSON, Level: slog.LevelInfo},
+			want:    []string{`"level":"INFO"`, `"msg":"hello"`, `"request_id":"abc"`},
+			exclude: []string{`"time"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			log, err := logger.New(&buf, tt.opts)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			ctx := logger.WithAttrs(context.Background(), slog.String("request_id", "abc"))
+			log.InfoContext(ctx, "hello")
+			log.DebugContext(ctx, "hidden")
+
+			got := buf.String()
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("New() logged %q, want %q in it", got, want)
+				}
+			}
+			for _, exclude := range append(tt.exclude, "hidden") {
+				if strings.Contains(got, exclude) {
+					t.Errorf("New() logged %q, want no %q in it", got, exclude)
+				}
+			}
+			if tt.opts.Format == logger.FormatJSON && !json.Valid(buf.Bytes()) {
+				t.Errorf("New() logged %q, want JSON", got)
+			}
+		})
+	}
+
+	_, err := logger.New(&bytes.Buffer{}, logger.Options{Format: "xml"})
+	if err == nil {
+		t.Error("New() error = nil, want an error for an unknown format")
+	}
+}
+
+func TestLevelHandler(t *testing.T) {
+	level := new(slog.LevelVar)
+	handler := logger.LevelHandler(level)
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+		wantLevel  slog.Level
+	}{
+		{"get", http.MethodGet, "", http.StatusOK, slog.LevelInfo},
+		{"set", http.MethodPut, "debug", http.StatusOK, slog.LevelDebug},
+		{"set with newline", http.MethodPut, "WARN\n", http.StatusOK, slog.LevelWarn},
+		{"invalid", http.MethodPut, "loud", http.StatusBadRequest, slog.LevelWarn},
+		{"too long", http.MethodPut, strings.Repeat("a", 100), http.StatusRequestEntityTooLarge, slog.LevelWarn},
+		{"post", http.MethodPost, "error", http.StatusMethodNotAllowed, slog.LevelWarn},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(tt.method, "/admin/log-level", strings.NewReader(tt.body)))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("LevelHandler() status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := level.Level(); got != tt.wantLevel {
+				t.Errorf("LevelHandler() level = %v, want %v", got, tt.wantLevel)
+			}
+		})
+	}
+}
@@ -0,0 +1,2 @@
+// Package metrics provides the Prometheus metrics of the web server.
+package metrics
@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// QueryHook is a bun query hook recording the duration of every query.
+type QueryHook struct{}
+
+var _ bun.QueryHook = QueryHook{}
+
+// BeforeQuery implements bun.QueryHook.
+func (QueryHook) BeforeQuery(ctx context.Context, _ *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+// AfterQuery implements bun.QueryHook.
+func (QueryHook) AfterQuery(_ context.Context, event *bun.QueryEvent) {
+	// Missing rows are missing pages to handlers, not failed queries.
+	failed := event.Err != nil && !errors.Is(event.Err, sql.ErrNoRows)
+	queryDuration.
+		WithLabelValues(event.Operation(), strconv.FormatBool(failed)).
+		Observe(time.Since(event.StartTime).Seconds())
+}
@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	// TokenVar names the environment variable holding the bearer token
+	// required to read the metrics; they are public if it is unset.
+	TokenVar = "METRICS_TOKEN"
+
+	namespace = "conneroh"
+)
+
+var (
+	// Registry holds every metric of the server, along with the Go runtime
+	// and process metrics.
+	Registry = prometheus.NewRegistry()
+
+	requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests served, by route pattern and status.",
+	}, []string{"route", "status"})
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Time taken to serve HTTP requests, by route pattern and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "status"})
+	renderDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "templ",
+		Name:      "render_duration_seconds",
+		Help:      "Time taken to render templ components, by route pattern.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route"})
+	cacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "lookups_total",
+		Help:      "Cache lookups, by cache and result (hit or miss).",
+	}, []string{"cache", "result"})
+	searchDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "search",
+		Name:      "query_duration_seconds",
+		Help:      "Time taken to filter documents by a search query.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 8),
+	})
+	queryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "Time taken by SQLite queries, by operation and outcome.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 8),
+	}, []string{"operation", "error"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		requests,
+		requestDuration,
+		renderDuration,
+		cacheLookups,
+		searchDuration,
+		queryDuration,
+	)
+}
+
+// ObserveRequest records a served request. Requests that matched no route
+// are recorded under the "unmatched" route to bound the label values.
+func ObserveRequest(route string, status int, took time.Duration) {
+	if route == "" {
+		route = "unmatched"
+	}
+	code := strconv.Itoa(status)
+	requests.WithLabelValues(route, code).Inc()
+	requestDuration.WithLabelValues(route, code).Observe(took.Seconds())
+}
+
+// ObserveRender records the time taken to render the component of a route.
+func ObserveRender(route string, took time.Duration) {
+	renderDuration.WithLabelValues(route).Observe(took.Seconds())
+}
+
+// CacheLookup records a lookup in the named cache.
+func CacheLookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheLookups.WithLabelValues(cache, result).Inc()
+}
+
+// ObserveSearch records the time taken to filter documents by a query.
+func ObserveSearch(took time.Duration) {
+	searchDuration.Observe(took.Seconds())
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,285 @@
+package routing
+
+import (
+	"bytes"
+	"compress/gzip"
+	"container/list"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/conneroisu/conneroh.com/internal/assets"
+	"github.com/conneroisu/conneroh.com/internal/metrics"
+)
+
+const (
+	// DefaultMaxCachedResponses bounds the number of responses a
+	// ResponseCache keeps, as search queries make for unbounded URLs.
+	DefaultMaxCachedResponses = 2048
+
+	encodingGzip   = "gzip"
+	encodingBrotli = "br"
+	brotliLevel    = 9
+)
+
+// ResponseCache stores the rendered bytes of successful HTML responses along
+// with their gzip and brotli encodings, keyed by URL and by whether htmx asked
+// for a fragment, and answers conditional requests from them.
+//
+// Pages are rendered once per cache: a new cache is made when the content
+// changes.
+type ResponseCache struct {
+	// MaxEntries is how many responses are kept; storing another evicts
+	// the least recently used.
+	MaxEntries int
+
+	mu      sync.Mutex
+	entries map[cacheKey]*list.Element
+	// recent orders the entries from most to least recently used.
+	recent *list.List
+}
+
+// cacheKey identifies a cached response.
+type cacheKey struct {
+	uri  string
+	htmx bool
+}
+
+// cachedResponse is a rendered response and its encodings.
+type cachedResponse struct {
+	key      cacheKey
+	header   http.Header
+	modified time.Time
+	// variants maps content encodings to bodies, "" being uncompressed.
+	variants map[string]encodedBody
+}
+
+// encodedBody is an encoding of a response body with its strong ETag.
+type encodedBody struct {
+	etag string
+	body []byte
+}
+
+// NewResponseCache creates an empty ResponseCache.
+func NewResponseCache() *ResponseCache {
+	return &ResponseCache{
+		MaxEntries: DefaultMaxCachedResponses,
+		entries:    map[cacheKey]*list.Element{},
+		recent:     list.New(),
+	}
+}
+
+// get returns the response cached under key, marking it recently used.
+func (c *ResponseCache) get(key cacheKey) *cachedResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	c.recent.MoveToFront(elem)
+
+	return elem.Value.(*cachedResponse)
+}
+
+// put caches entry, evicting the least recently used responses past
+// MaxEntries.
+func (c *ResponseCache) put(entry *cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[entry.key]; ok {
+		elem.Value = entry
+		c.recent.MoveToFront(elem)
+
+		return
+	}
+	c.entries[entry.key] = c.recent.PushFront(entry)
+	for c.recent.Len() > max(c.MaxEntries, 0) {
+		oldest := c.recent.Remove(c.recent.Back()).(*cachedResponse)
+		delete(c.entries, oldest.key)
+	}
+}
+
+// Wrap returns a handler serving GET and HEAD requests from the cache,
+// rendering them with next on a miss.
+func (c *ResponseCache) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+		key := cacheKey{
+			uri:  r.URL.RequestURI(),
+			htmx: r.Header.Get(HdrRequest) != "",
+		}
+		entry := c.get(key)
+		metrics.CacheLookup("response", entry != nil)
+		if entry == nil {
+			if r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+			buf := newBufferedWriter()
+			next.ServeHTTP(buf, r)
+			if !buf.cacheable() {
+				buf.flush(w)
+
+				return
+			}
+			entry = newCachedResponse(key, buf)
+			c.put(entry)
+		}
+		entry.serve(w, r)
+	})
+}
+
+// newCachedResponse encodes a rendered response, keeping only the encodings
+// smaller than the original.
+func newCachedResponse(key cacheKey, buf *bufferedWriter) *cachedResponse {
+	body := buf.body.Bytes()
+	hash := assets.ComputeHash(body)
+	entry := &cachedResponse{
+		key:      key,
+		header:   buf.header.Clone(),
+		modified: time.Now().UTC(),
+		variants: map[string]encodedBody{
+			"": {etag: strconv.Quote(hash), body: body},
+		},
+	}
+	entry.header.Del("Content-Length")
+	entry.header.Del("Date")
+
+	var gz bytes.Buffer
+	gw, _ := gzip.NewWriterLevel(&gz, gzip.BestCompression)
+	_, _ = gw.Write(body)
+	_ = gw.Close()
+	var br bytes.Buffer
+	bw := brotli.NewWriterLevel(&br, brotliLevel)
+	_, _ = bw.Write(body)
+	_ = bw.Close()
+	for encoding, encoded := range map[string][]byte{
+		encodingGzip:   gz.Bytes(),
+		encodingBrotli: br.Bytes(),
+	} {
+		if len(encoded) < len(body) {
+			entry.variants[encoding] = encodedBody{
+				etag: strconv.Quote(hash + "-" + encoding),
+				body: encoded,
+			}
+		}
+	}
+
+	return entry
+}
+
+// serve writes the best encoding the client accepts, or a 304 if the client
+// already has it.
+func (e *cachedResponse) serve(w http.ResponseWriter, r *http.Request) {
+	encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), e.variants)
+	variant := e.variants[encoding]
+	header := w.Header()
+	for key, values := range e.header {
+		header[key] = values
+	}
+	header.Add("Vary", "Accept-Encoding")
+	header.Add("Vary", HdrRequest)
+	header.Set("ETag", variant.etag)
+	if encoding != "" {
+		header.Set("Content-Encoding", encoding)
+	}
+	if header.Get("Cache-Control") == "" {
+		// Revalidate every time, which the ETag makes cheap.
+		header.Set("Cache-Control", "no-cache")
+	}
+	http.ServeContent(w, r, "", e.modified, bytes.NewReader(variant.body))
+}
+
+// negotiateEncoding picks the available content encoding the client prefers,
+// favoring brotli on ties, or "" for the uncompressed body.
+func negotiateEncoding(accept string, variants map[string]encodedBody) string {
+	var (
+		best  string
+		bestQ float64
+	)
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := variants[name]; !ok || name == "" {
+			continue
+		}
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > bestQ || (q == bestQ && q > 0 && name == encodingBrotli) {
+			best, bestQ = name, q
+		}
+	}
+
+	return best
+}
+
+// bufferedWriter records a response so it can be cached before it is sent.
+type bufferedWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedWriter() *bufferedWriter {
+	return &bufferedWriter{header: http.Header{}}
+}
+
+// Header implements http.ResponseWriter.
+func (b *bufferedWriter) Header() http.Header {
+	return b.header
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (b *bufferedWriter) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+// Write implements http.ResponseWriter.
+func (b *bufferedWriter) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+
+	return b.body.Write(p)
+}
+
+// cacheable returns true for successful, non-empty HTML responses that do
+// not set cookies, opt out of caching or allow scripts by a nonce, which must
+// not be reused.
+func (b *bufferedWriter) cacheable() bool {
+	return b.status == http.StatusOK &&
+		b.body.Len() > 0 &&
+		strings.HasPrefix(b.header.Get("Content-Type"), "text/html") &&
+		b.header.Get("Set-Cookie") == "" &&
+		!strings.Contains(b.header.Get("Cache-Control"), "no-store") &&
+		!strings.Contains(b.header.Get("Content-Security-Policy"), "'nonce-") &&
+		!strings.Contains(b.header.Get("Content-Security-Policy-Report-Only"), "'nonce-")
+}
+
+// flush sends the recorded response as it is.
+func (b *bufferedWriter) flush(w http.ResponseWriter) {
+	for key, values := range b.header {
+		w.Header()[key] = values
+	}
+	if b.status != 0 {
+		w.WriteHeader(b.status)
+	}
+	_, _ = w.Write(b.body.Bytes())
+}
@@ -0,0 +1,242 @@
+package routing_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/conneroisu/conneroh.com/internal/routing"
+)
+
+// page is a body long enough for its gzip and brotli encodings to be smaller.
+var page = "<html><body>" + strings.Repeat("<p>cached</p>", 64) + "</body></html>"
+
+// counting returns a handler serving page as HTML and a count of the
+// requests it rendered.
+func counting() (http.Handler, *int) {
+	var renders int
+
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		renders++
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(page))
+	}), &renders
+}
+
+// get serves a GET request for target with the given headers.
+func get(handler http.Handler, target string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestResponseCacheEncoding(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{name: "None", accept: "", want: ""},
+		{name: "Gzip", accept: "gzip", want: "gzip"},
+		{name: "Brotli", accept: "br", want: "br"},
+		{name: "Brotli on ties", accept: "gzip, deflate, br", want: "br"},
+		{name: "Quality", accept: "br;q=0.5, gzip;q=0.8", want: "gzip"},
+		{name: "Refused", accept: "br;q=0, gzip;q=0", want: ""},
+		{name: "Unknown", accept: "zstd, deflate", want: ""},
+		{name: "Case and spaces", accept: " GZIP ; q=1 ", want: "gzip"},
+	}
+	next, _ := counting()
+	handler := routing.NewResponseCache().Wrap(next)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := get(handler, "/posts", map[string]string{"Accept-Encoding": tt.accept})
+			if got := rec.Header().Get("Content-Encoding"); got != tt.want {
+				t.Errorf("Content-Encoding = %q, want %q", got, tt.want)
+			}
+			if tt.want == "" && rec.Body.String() != page {
+				t.Errorf("body = %q, want the page", rec.Body.String())
+			}
+			vary := rec.Header().Values("Vary")
+			if !slices.Contains(vary, "Accept-Encoding") || !slices.Contains(vary, routing.HdrRequest) {
+				t.Errorf("Vary = %v, want Accept-Encoding and %s", vary, routing.HdrRequest)
+			}
+		})
+	}
+}
+
+func TestResponseCacheConditional(t *testing.T) {
+	next, renders := counting()
+	handler := routing.NewResponseCache().Wrap(next)
+	first := get(handler, "/posts", nil)
+	etag := first.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("ETag is not set")
+	}
+	modified := first.Header().Get("Last-Modified")
+	later := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	tests := []struct {
+		name       string
+		header     map[string]string
+		wantStatus int
+	}{
+		{name: "Matching ETag", header: map[string]string{"If-None-Match": etag}, wantStatus: http.StatusNotModified},
+		{name: "Weak ETag", header: map[string]string{"If-None-Match": "W/" + etag}, wantStatus: http.StatusNotModified},
+		{name: "Other ETag", header: map[string]string{"If-None-Match": `"other"`}, wantStatus: http.StatusOK},
+		{
+			name:       "ETag of another encoding",
+			header:     map[string]string{"If-None-Match": etag, "Accept-Encoding": "br"},
+			wantStatus: http.StatusOK,
+		},
+		{name: "Unmodified", header: map[string]string{"If-Modified-Since": modified}, wantStatus: http.StatusNotModified},
+		{name: "Modified later", header: map[string]string{"If-Modified-Since": later}, wantStatus: http.StatusNotModified},
+		{
+			name:       "ETag wins over date",
+			header:     map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": modified},
+			wantStatus: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := get(handler, "/posts", tt.header)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusNotModified && rec.Body.Len() != 0 {
+				t.Errorf("body = %q, want none", rec.Body.String())
+			}
+		})
+	}
+	if *renders != 1 {
+		t.Errorf("rendered %d times, want 1", *renders)
+	}
+}
+
+func TestResponseCacheCacheable(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		status      int
+		header      map[string]string
+		body        string
+		htmx        bool
+		wantRenders int
+	}{
+		{name: "Page", wantRenders: 1},
+		{name: "Fragment", htmx: true, wantRenders: 1},
+		{name: "Head", method: http.MethodHead, wantRenders: 2},
+		{name: "Post", method: http.MethodPost, wantRenders: 2},
+		{name: "Not found", status: http.StatusNotFound, wantRenders: 2},
+		{name: "Empty", body: "-", wantRenders: 2},
+		{name: "Not HTML", header: map[string]string{"Content-Type": "application/json"}, wantRenders: 2},
+		{name: "Cookie", header: map[string]string{"Set-Cookie": "session=1"}, wantRenders: 2},
+		{name: "No store", header: map[string]string{"Cache-Control": "no-store"}, wantRenders: 2},
+		{
+			name:        "Hashed scripts",
+			header:      map[string]string{"Content-Security-Policy": "script-src 'self' 'sha256-abc='"},
+			wantRenders: 1,
+		},
+		{
+			name:        "Nonce",
+			header:      map[string]string{"Content-Security-Policy": "script-src 'self' 'nonce-abc='"},
+			wantRenders: 2,
+		},
+		{
+			name:        "Report only nonce",
+			header:      map[string]string{"Content-Security-Policy-Report-Only": "script-src 'nonce-abc='"},
+			wantRenders: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var renders int
+			handler := routing.NewResponseCache().Wrap(http.HandlerFunc(
+				func(w http.ResponseWriter, _ *http.Request) {
+					renders++
+					w.Header().Set("Content-Type", "text/html")
+					for name, value := range tt.header {
+						w.Header().Set(name, value)
+					}
+					if tt.status != 0 {
+						w.WriteHeader(tt.status)
+					}
+					if tt.body != "-" {
+						_, _ = w.Write([]byte(page))
+					}
+				}))
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			for range 2 {
+				req := httptest.NewRequest(method, "/posts", nil)
+				if tt.htmx {
+					req.Header.Set(routing.HdrRequest, "true")
+				}
+				handler.ServeHTTP(httptest.NewRecorder(), req)
+			}
+			if renders != tt.wantRenders {
+				t.Errorf("rendered %d times, want %d", renders, tt.wantRenders)
+			}
+		})
+	}
+}
+
+func TestResponseCacheKeys(t *testing.T) {
+	next, renders := counting()
+	handler := routing.NewResponseCache().Wrap(next)
+	for _, req := range []struct {
+		target string
+		htmx   bool
+	}{
+		{"/posts", false},
+		{"/posts", true},
+		{"/posts?page=2", false},
+		{"/posts", false},
+		{"/posts", true},
+	} {
+		header := map[string]string{}
+		if req.htmx {
+			header[routing.HdrRequest] = "true"
+		}
+		get(handler, req.target, header)
+	}
+	if *renders != 3 {
+		t.Errorf("rendered %d times, want 3", *renders)
+	}
+}
+
+func TestResponseCacheEviction(t *testing.T) {
+	next, renders := counting()
+	cache := routing.NewResponseCache()
+	cache.MaxEntries = 2
+	handler := cache.Wrap(next)
+	tests := []struct {
+		target     string
+		wantRender bool
+	}{
+		{"/a", true},
+		{"/b", true},
+		{"/a", false},
+		// Evicts /b, the least recently used.
+		{"/search/posts?search=x", true},
+		{"/a", false},
+		// Evicts the search.
+		{"/b", true},
+		{"/search/posts?search=x", true},
+	}
+	for i, tt := range tests {
+		before := *renders
+		get(handler, tt.target, nil)
+		if rendered := *renders > before; rendered != tt.wantRender {
+			t.Errorf("request %d for %s rendered = %v, want %v", i, tt.target, rendered, tt.wantRender)
+		}
+	}
+}
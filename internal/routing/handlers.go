@@ -1,10 +1,15 @@
 package routing
 
 import (
+	"io/fs"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/a-h/templ"
+	"github.com/conneroisu/conneroh.com/internal/metrics"
+	"github.com/conneroisu/conneroh.com/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // APIFunc is a function that handles an API request and returns an error.
@@ -15,7 +20,9 @@ func Make(fn APIFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		err := fn(w, r)
 		if err != nil {
-			slog.Error(
+			recordError(r.Context(), err)
+			slog.ErrorContext(
+				r.Context(),
 				"api error",
 				"err",
 				err.Error(),
@@ -32,7 +39,14 @@ func Make(fn APIFunc) http.HandlerFunc {
 					"/500",
 					http.StatusFound,
 				) // 302 Found or http.StatusTemporaryRedirect (307)
+
+				return
 			}
+			http.Error(
+				w,
+				http.StatusText(http.StatusInternalServerError),
+				http.StatusInternalServerError,
+			)
 		}
 	}
 }
@@ -45,11 +59,49 @@ func MorphableHandler(
 	morph templ.Component,
 ) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
 		header := r.Header.Get(HdrRequest)
+		ctx, span := tracing.Start(
+			r.Context(),
+			"render",
+			attribute.Bool("htmx", header != ""),
+		)
+		defer span.End()
+		r = r.WithContext(ctx)
 		if header == "" {
 			templ.Handler(wrapper(morph)).ServeHTTP(w, r)
 		} else {
 			templ.Handler(morph).ServeHTTP(w, r)
 		}
+		metrics.ObserveRender(route(ctx), time.Since(start))
+	}
+}
+
+// FileServer returns a handler serving the files of root like
+// http.FileServer, but answering 404 for directories instead of listing
+// their contents.
+func FileServer(root http.FileSystem) http.Handler {
+	return http.FileServer(filesOnly{root})
+}
+
+// filesOnly is a http.FileSystem that hides the directories of its
+// underlying file system.
+type filesOnly struct {
+	http.FileSystem
+}
+
+// Open implements http.FileSystem.
+func (fsys filesOnly) Open(name string) (http.File, error) {
+	f, err := fsys.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
 	}
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		_ = f.Close()
+
+		return nil, fs.ErrNotExist
+	}
+
+	return f, nil
 }
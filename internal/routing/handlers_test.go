@@ -0,0 +1,44 @@
+package routing_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/conneroisu/conneroh.com/internal/routing"
+)
+
+func TestFileServer(t *testing.T) {
+	handler := routing.FileServer(http.FS(fstest.MapFS{
+		"banner.png":        {Data: []byte("png")},
+		"diagrams/flow.svg": {Data: []byte("<svg/>")},
+		"site/index.html":   {Data: []byte("<html/>")},
+	}))
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "File", path: "/banner.png", wantStatus: http.StatusOK, wantBody: "png"},
+		{name: "Nested file", path: "/diagrams/flow.svg", wantStatus: http.StatusOK, wantBody: "<svg/>"},
+		{name: "Root directory", path: "/", wantStatus: http.StatusNotFound},
+		{name: "Directory", path: "/diagrams/", wantStatus: http.StatusNotFound},
+		{name: "Directory without slash", path: "/diagrams", wantStatus: http.StatusNotFound},
+		{name: "Directory with an index", path: "/site/", wantStatus: http.StatusNotFound},
+		{name: "Missing file", path: "/missing.png", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("ServeHTTP(%q) status = %d, want %d", tt.path, rec.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("ServeHTTP(%q) body = %q, want %q", tt.path, rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,325 @@
+package routing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/conneroisu/conneroh.com/internal/logger"
+	"github.com/conneroisu/conneroh.com/internal/metrics"
+	"github.com/conneroisu/conneroh.com/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// HdrRequestID is the header carrying the ID of a request, which is
+	// taken from the client or a proxy when it sends a valid one.
+	HdrRequestID = "X-Request-ID"
+
+	maxRequestIDLen = 64
+	requestIDSize   = 8
+)
+
+// Middleware wraps a handler with behavior shared by every route.
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps h in middlewares, the first being the outermost.
+func Chain(h http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+
+	return h
+}
+
+// requestInfo is what the middlewares learn about a request while it is
+// served and report once it is done.
+type requestInfo struct {
+	id    string
+	route string
+	err   error
+}
+
+// infoKey is the context key of the requestInfo of a request.
+type infoKey struct{}
+
+// withInfo returns r with a requestInfo in its context, creating one unless
+// an outer middleware already did.
+func withInfo(r *http.Request) (*http.Request, *requestInfo) {
+	if info, ok := r.Context().Value(infoKey{}).(*requestInfo); ok {
+		return r, info
+	}
+	info := &requestInfo{}
+
+	return r.WithContext(context.WithValue(r.Context(), infoKey{}, info)), info
+}
+
+// RequestID returns the ID of the request being served with ctx, or "" if
+// RequestIDs did not assign one.
+func RequestID(ctx context.Context) string {
+	if info, ok := ctx.Value(infoKey{}).(*requestInfo); ok {
+		return info.id
+	}
+
+	return ""
+}
+
+// route returns the route pattern ResolveRoute recorded for the request
+// being served with ctx.
+func route(ctx context.Context) string {
+	if info, ok := ctx.Value(infoKey{}).(*requestInfo); ok {
+		return info.route
+	}
+
+	return ""
+}
+
+// recordError records the error a handler failed with for the access log.
+func recordError(ctx context.Context, err error) {
+	if info, ok := ctx.Value(infoKey{}).(*requestInfo); ok {
+		info.err = err
+	}
+}
+
+// RequestIDs assigns every request an ID, echoes it in the response and adds
+// it to the records logged with the request's context.
+func RequestIDs(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, info := withInfo(r)
+		info.id = r.Header.Get(HdrRequestID)
+		if !validRequestID(info.id) {
+			info.id = newRequestID()
+		}
+		w.Header().Set(HdrRequestID, info.id)
+		ctx := logger.WithAttrs(r.Context(), slog.String("request_id", info.id))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ResolveRoute records the route pattern resolve returns for each request,
+// so that responses served without reaching the router, such as cached ones,
+// are still logged and timed by route.
+func ResolveRoute(resolve func(*http.Request) string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r, info := withInfo(r)
+			info.route = resolve(r)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// AccessLog logs every request once its response is sent, with its route,
+// status, size, latency and the error it failed with, if any.
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		r, info := withInfo(r)
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("url", r.URL.RequestURI()),
+			slog.String("route", info.route),
+			slog.Int("status", sw.Status()),
+			slog.Int64("bytes", sw.bytes),
+			slog.Duration("duration", time.Since(start)),
+		}
+		level := slog.LevelInfo
+		if info.err != nil {
+			attrs = append(attrs, slog.String("err", info.err.Error()))
+		}
+		if sw.Status() >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.LogAttrs(r.Context(), level, "request", attrs...)
+	})
+}
+
+// Instrument records the count and latency of requests by route and status
+// in the Prometheus metrics.
+func Instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		r, info := withInfo(r)
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		metrics.ObserveRequest(info.route, sw.Status(), time.Since(start))
+	})
+}
+
+// Trace serves every request in a server span named after its route,
+// continuing the trace of the client if it sent a traceparent header, and
+// adds the trace ID to the records logged with the request's context.
+func Trace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, info := withInfo(r)
+		ctx := otel.GetTextMapPropagator().Extract(
+			r.Context(),
+			propagation.HeaderCarrier(r.Header),
+		)
+		name := info.route
+		if name == "" {
+			name = r.Method
+		}
+		ctx, span := tracing.Tracer().Start(
+			ctx,
+			name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+				semconv.HTTPRoute(info.route),
+			),
+		)
+		defer span.End()
+		if sc := span.SpanContext(); sc.IsSampled() {
+			ctx = logger.WithAttrs(ctx, slog.String("trace_id", sc.TraceID().String()))
+		}
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(sw.Status()))
+		if sw.Status() >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(sw.Status()))
+		}
+		if info.err != nil {
+			span.RecordError(info.err)
+		}
+	})
+}
+
+// Timing reports how long the route took to start responding in the
+// Server-Timing header, shown by browser developer tools.
+func Timing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		r, info := withInfo(r)
+		sw := &statusWriter{ResponseWriter: w}
+		sw.beforeHeader = func(h http.Header) {
+			ms := float64(time.Since(start).Microseconds()) / 1000
+			h.Add("Server-Timing", fmt.Sprintf(
+				"route;desc=%s;dur=%.3f",
+				strconv.Quote(info.route),
+				ms,
+			))
+		}
+		next.ServeHTTP(sw, r)
+	})
+}
+
+// Recover turns a panicking handler into a 500 rendered by fallback, unless
+// the response had already started, logging the panic and its stack.
+func Recover(fallback http.Handler) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r, info := withInfo(r)
+			sw := &statusWriter{ResponseWriter: w}
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+				info.err = fmt.Errorf("panic: %v", p)
+				slog.ErrorContext(
+					r.Context(),
+					"handler panicked",
+					slog.Any("panic", p),
+					slog.String("stack", string(debug.Stack())),
+				)
+				if !sw.wroteHeader {
+					fallback.ServeHTTP(sw, r)
+				}
+			}()
+			next.ServeHTTP(sw, r)
+		})
+	}
+}
+
+// statusWriter records the status and size of a response.
+type statusWriter struct {
+	http.ResponseWriter
+
+	status      int
+	bytes       int64
+	wroteHeader bool
+	// beforeHeader, if set, is called with the header just before it is
+	// sent.
+	beforeHeader func(http.Header)
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.status = status
+		if w.beforeHeader != nil {
+			w.beforeHeader(w.Header())
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter.
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+
+	return n, err
+}
+
+// Unwrap returns the wrapped writer for http.ResponseController.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Status returns the status of the response, 200 if none was written.
+func (w *statusWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+
+	return w.status
+}
+
+// validRequestID returns true if id is safe to log and echo back.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9',
+			r == '-', r == '_', r == '.':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// newRequestID returns a random request ID.
+func newRequestID() string {
+	b := make([]byte, requestIDSize)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
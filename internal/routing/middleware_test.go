@@ -0,0 +1,192 @@
+package routing_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/conneroisu/conneroh.com/internal/logger"
+	"github.com/conneroisu/conneroh.com/internal/routing"
+)
+
+// captureLogs makes the default logger write JSON records to the returned
+// buffer until the end of the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(logger.NewContextHandler(slog.NewJSONHandler(&buf, nil))))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	return &buf
+}
+
+func TestRequestIDs(t *testing.T) {
+	generated := regexp.MustCompile(`^[0-9a-f]{16}$`)
+	tests := []struct {
+		name string
+		id   string
+		// want is the expected ID, "" for a generated one.
+		want string
+	}{
+		{name: "Propagated", id: "abc-123_X.y", want: "abc-123_X.y"},
+		{name: "Absent"},
+		{name: "Unsafe", id: "abc\n123"},
+		{name: "Too long", id: strings.Repeat("a", 65)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := routing.RequestIDs(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				seen = routing.RequestID(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.id != "" {
+				req.Header.Set(routing.HdrRequestID, tt.id)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			got := rec.Header().Get(routing.HdrRequestID)
+			if tt.want != "" && got != tt.want {
+				t.Errorf("%s = %q, want %q", routing.HdrRequestID, got, tt.want)
+			}
+			if tt.want == "" && !generated.MatchString(got) {
+				t.Errorf("%s = %q, want a generated ID", routing.HdrRequestID, got)
+			}
+			if seen != got {
+				t.Errorf("RequestID() = %q, want %q", seen, got)
+			}
+		})
+	}
+}
+
+func TestRecover(t *testing.T) {
+	captureLogs(t)
+	fallback := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte("fallback"))
+	})
+	handler := routing.Chain(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/panic":
+				panic("boom")
+			case "/late":
+				_, _ = w.Write([]byte("partial"))
+				panic("boom")
+			}
+			_, _ = w.Write([]byte("ok"))
+		}),
+		routing.Recover(fallback),
+	)
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{path: "/panic", wantStatus: http.StatusInternalServerError, wantBody: "fallback"},
+		{path: "/ok", wantStatus: http.StatusOK, wantBody: "ok"},
+		{path: "/late", wantStatus: http.StatusOK, wantBody: "partial"},
+		{path: "/ok", wantStatus: http.StatusOK, wantBody: "ok"},
+	}
+	for _, tt := range tests {
+		rec := get(handler, tt.path, nil)
+		if rec.Code != tt.wantStatus || rec.Body.String() != tt.wantBody {
+			t.Errorf("GET %s = %d %q, want %d %q", tt.path, rec.Code, rec.Body.String(), tt.wantStatus, tt.wantBody)
+		}
+	}
+
+	t.Run("Aborted", func(t *testing.T) {
+		defer func() {
+			if p := recover(); p != http.ErrAbortHandler {
+				t.Errorf("recover() = %v, want %v", p, http.ErrAbortHandler)
+			}
+		}()
+		aborting := routing.Recover(fallback)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			panic(http.ErrAbortHandler)
+		}))
+		get(aborting, "/", nil)
+	})
+}
+
+func TestAccessLog(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		panics    bool
+		wantLevel string
+	}{
+		{name: "OK", body: "hello", wantLevel: "INFO"},
+		{name: "Not found", status: http.StatusNotFound, body: "missing", wantLevel: "INFO"},
+		{name: "Empty", status: http.StatusNoContent, wantLevel: "INFO"},
+		{name: "Panic", panics: true, body: "fallback", wantLevel: "ERROR"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := captureLogs(t)
+			handler := routing.Chain(
+				http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+					if tt.panics {
+						panic("boom")
+					}
+					if tt.status != 0 {
+						w.WriteHeader(tt.status)
+					}
+					_, _ = w.Write([]byte(tt.body))
+				}),
+				routing.RequestIDs,
+				routing.ResolveRoute(func(*http.Request) string { return "GET /posts" }),
+				routing.AccessLog,
+				routing.Recover(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+					w.WriteHeader(http.StatusInternalServerError)
+					_, _ = w.Write([]byte("fallback"))
+				})),
+			)
+			rec := get(handler, "/posts?page=2", map[string]string{routing.HdrRequestID: "req-1"})
+
+			var got struct {
+				Level     string `json:"level"`
+				Msg       string `json:"msg"`
+				Method    string `json:"method"`
+				URL       string `json:"url"`
+				Route     string `json:"route"`
+				Status    int    `json:"status"`
+				Bytes     int    `json:"bytes"`
+				RequestID string `json:"request_id"`
+				Err       string `json:"err"`
+			}
+			var found bool
+			for line := range strings.Lines(buf.String()) {
+				if err := json.Unmarshal([]byte(line), &got); err == nil && got.Msg == "request" {
+					found = true
+
+					break
+				}
+			}
+			if !found {
+				t.Fatalf("no request record in %s", buf)
+			}
+			if got.Status != rec.Code || got.Bytes != rec.Body.Len() {
+				t.Errorf("logged status %d and %d bytes, want %d and %d", got.Status, got.Bytes, rec.Code, rec.Body.Len())
+			}
+			if got.Level != tt.wantLevel {
+				t.Errorf("logged level = %s, want %s", got.Level, tt.wantLevel)
+			}
+			if got.Method != http.MethodGet || got.URL != "/posts?page=2" || got.Route != "GET /posts" {
+				t.Errorf("logged %s %s on %q, want GET /posts?page=2 on %q", got.Method, got.URL, got.Route, "GET /posts")
+			}
+			if got.RequestID != "req-1" {
+				t.Errorf("logged request_id = %q, want %q", got.RequestID, "req-1")
+			}
+			if tt.panics && got.Err == "" {
+				t.Error("logged no error for a panic")
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package routing
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a-h/templ"
+	"github.com/rotisserie/eris"
+)
+
+const (
+	// NonceSource is replaced in a CSP by the nonce generated for each
+	// response, which templ components read with templ.GetNonce.
+	NonceSource = "'nonce'"
+
+	// ContentSecurityPolicyVar names the environment variable replacing the
+	// default Content Security Policy, e.g. "default-src 'self'; script-src
+	// 'self' 'nonce'".
+	ContentSecurityPolicyVar = "CONTENT_SECURITY_POLICY"
+	// CSPReportOnlyVar names the environment variable that, when "true",
+	// reports Content Security Policy violations instead of enforcing them.
+	CSPReportOnlyVar = "CSP_REPORT_ONLY"
+	// HSTSMaxAgeVar names the environment variable setting how long browsers
+	// remember to only use HTTPS, as a duration; "0" disables HSTS.
+	HSTSMaxAgeVar = "HSTS_MAX_AGE"
+
+	// DefaultHSTSMaxAge is how long browsers are told to only use HTTPS.
+	DefaultHSTSMaxAge = 365 * 24 * time.Hour
+
+	nonceSize = 16
+)
+
+// CSP is a Content Security Policy, mapping directives to their sources.
+type CSP map[string][]string
+
+// ParseCSP parses a Content Security Policy header value.
+func ParseCSP(policy string) CSP {
+	csp := CSP{}
+	for _, directive := range strings.Split(policy, ";") {
+		fields := strings.Fields(directive)
+		if len(fields) == 0 {
+			continue
+		}
+		csp[strings.ToLower(fields[0])] = fields[1:]
+	}
+
+	return csp
+}
+
+// Clone returns a copy of c that can be changed without changing c.
+func (c CSP) Clone() CSP {
+	clone := make(CSP, len(c))
+	for directive, sources := range c {
+		clone[directive] = slices.Clone(sources)
+	}
+
+	return clone
+}
+
+// Add appends sources to a directive, skipping those it already has.
+func (c CSP) Add(directive string, sources ...string) {
+	for _, source := range sources {
+		if !slices.Contains(c[directive], source) {
+			c[directive] = append(c[directive], source)
+		}
+	}
+}
+
+// Remove drops sources from a directive.
+func (c CSP) Remove(directive string, sources ...string) {
+	c[directive] = slices.DeleteFunc(c[directive], func(source string) bool {
+		return slices.Contains(sources, source)
+	})
+}
+
+// UsesNonce returns true if any directive allows NonceSource.
+func (c CSP) UsesNonce() bool {
+	for _, sources := range c {
+		if slices.Contains(sources, NonceSource) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Header returns the header value of the policy with NonceSource replaced by
+// the given nonce, directives sorted by name.
+func (c CSP) Header(nonce string) string {
+	directives := make([]string, 0, len(c))
+	for directive, sources := range c {
+		parts := []string{directive}
+		for _, source := range sources {
+			if source == NonceSource {
+				source = "'nonce-" + nonce + "'"
+			}
+			parts = append(parts, source)
+		}
+		directives = append(directives, strings.Join(parts, " "))
+	}
+	slices.Sort(directives)
+
+	return strings.Join(directives, "; ")
+}
+
+// HashSource returns the source allowing an inline script or style whose
+// content is content.
+func HashSource(content string) string {
+	sum := sha256.Sum256([]byte(content))
+
+	return "'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'"
+}
+
+// SecurityPolicy is the set of security headers sent with every response.
+type SecurityPolicy struct {
+	// CSP is the Content Security Policy; none is sent if it is empty.
+	CSP CSP
+	// CSPReportOnly reports violations of CSP instead of enforcing it.
+	CSPReportOnly bool
+	// HSTSMaxAge is the max-age of Strict-Transport-Security; none is sent
+	// if it is zero.
+	HSTSMaxAge time.Duration
+	// ReferrerPolicy is the Referrer-Policy header.
+	ReferrerPolicy string
+	// PermissionsPolicy is the Permissions-Policy header.
+	PermissionsPolicy string
+}
+
+// DefaultSecurityPolicy returns a strict policy: same-origin resources and
+// nonced inline scripts only and no framing. No CORS headers are sent, as
+// the site serves no cross-origin API.
+func DefaultSecurityPolicy() SecurityPolicy {
+	return SecurityPolicy{
+		CSP: CSP{
+			"default-src":     {"'self'"},
+			"script-src":      {"'self'", NonceSource},
+			"style-src":       {"'self'"},
+			"img-src":         {"'self'", "data:"},
+			"object-src":      {"'none'"},
+			"base-uri":        {"'self'"},
+			"form-action":     {"'self'"},
+			"frame-ancestors": {"'none'"},
+		},
+		HSTSMaxAge:        DefaultHSTSMaxAge,
+		ReferrerPolicy:    "strict-origin-when-cross-origin",
+		PermissionsPolicy: "camera=(), microphone=(), geolocation=(), payment=(), usb=(), interest-cohort=()",
+	}
+}
+
+// Configure overrides the policy with the settings found in the environment.
+func (p *SecurityPolicy) Configure(getenv func(string) string) error {
+	if policy := getenv(ContentSecurityPolicyVar); policy != "" {
+		p.CSP = ParseCSP(policy)
+	}
+	if reportOnly := getenv(CSPReportOnlyVar); reportOnly != "" {
+		parsed, err := strconv.ParseBool(reportOnly)
+		if err != nil {
+			return eris.Wrapf(err, "invalid %s", CSPReportOnlyVar)
+		}
+		p.CSPReportOnly = parsed
+	}
+	if maxAge := getenv(HSTSMaxAgeVar); maxAge != "" {
+		parsed, err := time.ParseDuration(maxAge)
+		if err != nil || parsed < 0 {
+			return eris.Errorf("invalid %s %q: want a non-negative duration", HSTSMaxAgeVar, maxAge)
+		}
+		p.HSTSMaxAge = parsed
+	}
+
+	return nil
+}
+
+// Wrap returns a handler that sets the security headers of the policy before
+// calling next.
+//
+// When the CSP allows NonceSource, a fresh nonce is put in the request
+// context for templ.GetNonce. A ResponseCache wrapping this handler does not
+// store such responses, as every response must get a nonce of its own.
+func (p SecurityPolicy) Wrap(next http.Handler) http.Handler {
+	usesNonce := p.CSP.UsesNonce()
+	cspHeader := "Content-Security-Policy"
+	if p.CSPReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		if len(p.CSP) > 0 {
+			var nonce string
+			if usesNonce {
+				nonce = newNonce()
+				r = r.WithContext(templ.WithNonce(r.Context(), nonce))
+			}
+			header.Set(cspHeader, p.CSP.Header(nonce))
+		}
+		if p.HSTSMaxAge > 0 {
+			header.Set(
+				"Strict-Transport-Security",
+				"max-age="+strconv.Itoa(int(p.HSTSMaxAge.Seconds()))+"; includeSubDomains",
+			)
+		}
+		header.Set("X-Content-Type-Options", "nosniff")
+		if p.ReferrerPolicy != "" {
+			header.Set("Referrer-Policy", p.ReferrerPolicy)
+		}
+		if p.PermissionsPolicy != "" {
+			header.Set("Permissions-Policy", p.PermissionsPolicy)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// newNonce returns a random CSP nonce.
+func newNonce() string {
+	b := make([]byte, nonceSize)
+	_, _ = rand.Read(b)
+
+	return base64.StdEncoding.EncodeToString(b)
+}
@@ -0,0 +1,173 @@
+package routing_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/a-h/templ"
+	"github.com/conneroisu/conneroh.com/internal/routing"
+)
+
+func TestSecurityPolicyHeaders(t *testing.T) {
+	var nonce string
+	handler := routing.DefaultSecurityPolicy().Wrap(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			nonce = templ.GetNonce(r.Context())
+			w.WriteHeader(http.StatusOK)
+		}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/posts", nil))
+
+	if nonce == "" {
+		t.Fatal("Wrap() put no nonce in the request context")
+	}
+	csp := rec.Header().Get("Content-Security-Policy")
+	if !strings.Contains(csp, "script-src 'self' 'nonce-"+nonce+"'") {
+		t.Errorf("Content-Security-Policy = %q, want the nonce %q in script-src", csp, nonce)
+	}
+	if strings.Contains(csp, routing.NonceSource) {
+		t.Errorf("Content-Security-Policy = %q, want %s replaced", csp, routing.NonceSource)
+	}
+	want := map[string]string{
+		"Strict-Transport-Security":   "max-age=31536000; includeSubDomains",
+		"X-Content-Type-Options":      "nosniff",
+		"Referrer-Policy":             "strict-origin-when-cross-origin",
+		"Access-Control-Allow-Origin": "",
+	}
+	for name, value := range want {
+		if got := rec.Header().Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+	if rec.Header().Get("Permissions-Policy") == "" {
+		t.Error("Permissions-Policy is not set")
+	}
+
+	first := nonce
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/posts", nil))
+	if nonce == first {
+		t.Error("Wrap() reused the nonce of a previous response")
+	}
+}
+
+func TestSecurityPolicyConfigure(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		check   func(t *testing.T, p routing.SecurityPolicy)
+		wantErr bool
+	}{
+		{
+			name: "Defaults",
+			env:  map[string]string{},
+			check: func(t *testing.T, p routing.SecurityPolicy) {
+				if p.CSPReportOnly || p.HSTSMaxAge != routing.DefaultHSTSMaxAge {
+					t.Errorf("Configure() = %+v, want the defaults", p)
+				}
+			},
+		},
+		{
+			name: "Custom policy",
+			env: map[string]string{
+				routing.ContentSecurityPolicyVar: "default-src 'none'; img-src 'self' https:",
+				routing.CSPReportOnlyVar:         "true",
+				routing.HSTSMaxAgeVar:            "0",
+			},
+			check: func(t *testing.T, p routing.SecurityPolicy) {
+				if got := p.CSP.Header(""); got != "default-src 'none'; img-src 'self' https:" {
+					t.Errorf("Configure() CSP = %q", got)
+				}
+				if !p.CSPReportOnly || p.HSTSMaxAge != 0 {
+					t.Errorf("Configure() = %+v, want report-only without HSTS", p)
+				}
+			},
+		},
+		{
+			name:    "Invalid report only",
+			env:     map[string]string{routing.CSPReportOnlyVar: "maybe"},
+			wantErr: true,
+		},
+		{
+			name:    "Negative HSTS max age",
+			env:     map[string]string{routing.HSTSMaxAgeVar: "-1h"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := routing.DefaultSecurityPolicy()
+			err := p.Configure(func(key string) string { return tt.env[key] })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Configure() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.check != nil {
+				tt.check(t, p)
+			}
+		})
+	}
+}
+
+func TestSecurityPolicyNoCORS(t *testing.T) {
+	handler := routing.DefaultSecurityPolicy().Wrap(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	tests := []struct {
+		name   string
+		method string
+		path   string
+	}{
+		{name: "Page request", method: http.MethodGet, path: "/posts"},
+		{name: "Preflight", method: http.MethodOptions, path: "/posts"},
+		{name: "API path", method: http.MethodGet, path: "/api/posts"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Origin", "https://other.example")
+			req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Errorf("status = %d, want %d from the handler", rec.Code, http.StatusOK)
+			}
+			for name := range rec.Header() {
+				if strings.HasPrefix(name, "Access-Control-") {
+					t.Errorf("%s = %q, want no CORS headers", name, rec.Header().Get(name))
+				}
+			}
+		})
+	}
+}
+
+func TestHashSource(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "Empty", content: "", want: "'sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU='"},
+		{name: "Script", content: "alert('Hello, world.');", want: "'sha256-qznLcsROx4GACP2dm0UCKCzCG+HiZ1guq6ZZDob/Tng='"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := routing.HashSource(tt.content); got != tt.want {
+				t.Errorf("HashSource(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSPRemove(t *testing.T) {
+	csp := routing.DefaultSecurityPolicy().CSP
+	csp.Remove("script-src", routing.NonceSource, "'none'")
+	if csp.UsesNonce() {
+		t.Errorf("Remove() kept %s in %v", routing.NonceSource, csp["script-src"])
+	}
+	if got := csp.Header(""); !strings.Contains(got, "script-src 'self';") {
+		t.Errorf("Header() = %q, want script-src 'self'", got)
+	}
+}
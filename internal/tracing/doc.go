@@ -0,0 +1,3 @@
+// Package tracing provides the OpenTelemetry tracing of the web server and of
+// the content update.
+package tracing
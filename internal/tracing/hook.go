@@ -0,0 +1,50 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/uptrace/bun"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxQueryLen bounds the query text recorded on spans, as inserts of
+// rendered documents run to many kilobytes.
+const maxQueryLen = 1024
+
+// QueryHook is a bun query hook tracing every query as a client span.
+type QueryHook struct{}
+
+var _ bun.QueryHook = QueryHook{}
+
+// BeforeQuery implements bun.QueryHook.
+func (QueryHook) BeforeQuery(ctx context.Context, event *bun.QueryEvent) context.Context {
+	query := event.Query
+	if len(query) > maxQueryLen {
+		query = query[:maxQueryLen]
+	}
+	ctx, _ = tracer.Start(
+		ctx,
+		"db."+event.Operation(),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemSqlite,
+			semconv.DBOperationName(event.Operation()),
+			semconv.DBQueryText(query),
+		),
+	)
+
+	return ctx
+}
+
+// AfterQuery implements bun.QueryHook.
+func (QueryHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
+	err := event.Err
+	// Missing rows are missing pages to handlers, not failed queries.
+	if errors.Is(err, sql.ErrNoRows) {
+		err = nil
+	}
+	End(trace.SpanFromContext(ctx), err)
+}
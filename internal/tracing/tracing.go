@@ -0,0 +1,130 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/rotisserie/eris"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// ExporterVar names the environment variable selecting where spans are
+	// exported: "otlp", "stdout" (or "console") or "none".
+	//
+	// It defaults to "otlp" when an OTLP endpoint is configured through
+	// OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT, which
+	// the exporter reads along with the other standard OTLP variables, and
+	// to "none" otherwise.
+	ExporterVar = "OTEL_TRACES_EXPORTER"
+
+	instrumentation = "github.com/conneroisu/conneroh.com"
+)
+
+// ErrUnknownExporter is returned by Setup for an unsupported ExporterVar.
+var ErrUnknownExporter = eris.New("unknown trace exporter")
+
+// tracer starts every span of the module. It delegates to the provider Setup
+// installs, so it can be created before Setup is called.
+var tracer = otel.Tracer(instrumentation)
+
+// Tracer returns the tracer of the module.
+func Tracer() trace.Tracer {
+	return tracer
+}
+
+// Start starts a span as a child of the span in ctx.
+func Start(
+	ctx context.Context,
+	name string,
+	attrs ...attribute.KeyValue,
+) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends span, marking it failed with err unless err is nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Setup installs the global tracer provider and W3C trace context
+// propagation for the named service, exporting spans as configured in the
+// environment. The returned function flushes and stops the exporter.
+func Setup(
+	ctx context.Context,
+	getenv func(string) string,
+	service string,
+) (func(context.Context) error, error) {
+	exporter, err := newExporter(ctx, getenv)
+	if err != nil || exporter == nil {
+		return func(context.Context) error { return nil }, err
+	}
+	res, err := resource.New(
+		ctx,
+		resource.WithAttributes(semconv.ServiceName(service)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, eris.Wrap(err, "failed to describe trace resource")
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return provider.Shutdown, nil
+}
+
+// newExporter returns the exporter ExporterVar selects, or nil if tracing is
+// disabled.
+func newExporter(
+	ctx context.Context,
+	getenv func(string) string,
+) (sdktrace.SpanExporter, error) {
+	name := getenv(ExporterVar)
+	if name == "" {
+		name = "none"
+		if getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" ||
+			getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+			name = "otlp"
+		}
+	}
+	switch name {
+	case "none":
+		return nil, nil
+	case "otlp":
+		exporter, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, eris.Wrap(err, "failed to create OTLP trace exporter")
+		}
+
+		return exporter, nil
+	case "stdout", "console":
+		exporter, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+		if err != nil {
+			return nil, eris.Wrap(err, "failed to create stdout trace exporter")
+		}
+
+		return exporter, nil
+	default:
+		return nil, eris.Wrapf(ErrUnknownExporter, "%s=%q", ExporterVar, name)
+	}
+}
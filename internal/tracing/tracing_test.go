@@ -0,0 +1,137 @@
+package tracing_test
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/a-h/templ"
+	"github.com/conneroisu/conneroh.com/internal/routing"
+	"github.com/conneroisu/conneroh.com/internal/tracing"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	_ "modernc.org/sqlite"
+)
+
+// traceparent is a sampled remote span, as sent by a client.
+const (
+	traceparent  = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	remoteTrace  = "4bf92f3577b34da6a3ce929d0e0e4736"
+	remoteParent = "00f067aa0ba902b7"
+)
+
+// spans records the spans of the tracer of the module. The tracer delegates
+// to the first provider installed only, so it is installed once.
+var spans = tracetest.NewSpanRecorder()
+
+func init() {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+}
+
+func TestRequestSpans(t *testing.T) {
+	sqldb, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := bun.NewDB(sqldb, sqlitedialect.New())
+	defer db.Close()
+	db.AddQueryHook(tracing.QueryHook{})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /post/{slug}", func(w http.ResponseWriter, r *http.Request) {
+		var found int
+		err := db.NewRaw("SELECT 1 WHERE ? = 'hello'", r.PathValue("slug")).Scan(r.Context(), &found)
+		if err != nil {
+			http.NotFound(w, r)
+
+			return
+		}
+		routing.MorphableHandler(
+			func(c templ.Component) templ.Component { return c },
+			templ.ComponentFunc(func(_ context.Context, w io.Writer) error {
+				_, err := io.WriteString(w, "<p>hello</p>")
+
+				return err
+			}),
+		).ServeHTTP(w, r)
+	})
+	handler := routing.Chain(
+		mux,
+		routing.ResolveRoute(func(r *http.Request) string {
+			_, pattern := mux.Handler(r)
+
+			return pattern
+		}),
+		routing.Trace,
+	)
+
+	tests := []struct {
+		name        string
+		target      string
+		traceparent string
+		// wantSpans are the names of the spans expected under the server
+		// span, in the order they ended.
+		wantSpans []string
+	}{
+		{name: "Rendered", target: "/post/hello", wantSpans: []string{"db.SELECT", "render"}},
+		{name: "Missing row", target: "/post/missing", wantSpans: []string{"db.SELECT"}},
+		{
+			name:        "Remote parent",
+			target:      "/post/hello",
+			traceparent: traceparent,
+			wantSpans:   []string{"db.SELECT", "render"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(spans.Ended())
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.traceparent != "" {
+				req.Header.Set("traceparent", tt.traceparent)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			ended := spans.Ended()[before:]
+			if len(ended) != len(tt.wantSpans)+1 {
+				t.Fatalf("ended %d spans, want %d", len(ended), len(tt.wantSpans)+1)
+			}
+			server := ended[len(ended)-1]
+			if server.Name() != "GET /post/{slug}" || server.SpanKind() != trace.SpanKindServer {
+				t.Errorf("last span = %s of kind %v, want the server span of the route", server.Name(), server.SpanKind())
+			}
+			if tt.traceparent != "" {
+				if got := server.SpanContext().TraceID().String(); got != remoteTrace {
+					t.Errorf("server span trace = %s, want %s", got, remoteTrace)
+				}
+				if got := server.Parent().SpanID().String(); got != remoteParent {
+					t.Errorf("server span parent = %s, want %s", got, remoteParent)
+				}
+			} else if server.Parent().IsValid() {
+				t.Errorf("server span parent = %v, want none", server.Parent())
+			}
+			for i, want := range tt.wantSpans {
+				span := ended[i]
+				if span.Name() != want {
+					t.Errorf("span %d = %s, want %s", i, span.Name(), want)
+				}
+				if span.Parent().SpanID() != server.SpanContext().SpanID() {
+					t.Errorf("span %s parent = %s, want the server span %s",
+						span.Name(), span.Parent().SpanID(), server.SpanContext().SpanID())
+				}
+				if span.Status().Code == codes.Error {
+					t.Errorf("span %s status = %v, want no error", span.Name(), span.Status())
+				}
+			}
+		})
+	}
+}
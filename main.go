@@ -16,6 +16,7 @@ func main() {
 	slog.SetDefault(logger.DefaultProdLogger)
 	if err = conneroh.Run(
 		context.Background(),
+		os.Args[1:],
 		os.Getenv,
 	); err != nil {
 		fmt.Println(err)